@@ -8,8 +8,10 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
@@ -19,8 +21,16 @@ import (
 
 var (
 	viperConfMap map[string]*viper.Viper // 用来存放配置文件缓存
+	confMu       sync.RWMutex
+
+	changeMu    sync.RWMutex
+	changeHooks []*changeHook // 配置文件变化回调
 )
 
+type changeHook struct {
+	fn func(name string)
+}
+
 //读取顺序：环境变量>文件内容
 func New(file string) error {
 	//判断文件是否存在
@@ -36,33 +46,85 @@ func New(file string) error {
 
 //读取文件内容
 func readConfig(file string) error {
-	bts, err := ioutil.ReadFile(file)
+	v, err := loadConfig(file)
 	if err != nil {
 		return err
 	}
+	filename := strings.TrimSuffix(path.Base(file), path.Ext(file))
+	confMu.Lock()
+	if viperConfMap == nil {
+		viperConfMap = make(map[string]*viper.Viper)
+	}
+	viperConfMap[filename] = v
+	confMu.Unlock()
+	//监听文件变化
+	//环境变量替换是写在viper的override层的，重新加载时需要用新的viper替换，否则旧值会覆盖文件内容
+	v.OnConfigChange(func(e fsnotify.Event) {
+		nv, err := loadConfig(file)
+		if err != nil {
+			return
+		}
+		confMu.Lock()
+		viperConfMap[filename] = nv
+		confMu.Unlock()
+		changeMu.RLock()
+		hooks := changeHooks
+		changeMu.RUnlock()
+		for _, h := range hooks {
+			h.fn(filename)
+		}
+	})
+	v.WatchConfig()
+	return nil
+}
+
+func loadConfig(file string) (*viper.Viper, error) {
+	bts, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
 
 	if !in(strings.Split(path.Ext(file), ".")[1], viper.SupportedExts) {
-		return fmt.Errorf("支持的文件类型：【%s\n】", viper.SupportedExts)
+		return nil, fmt.Errorf("支持的文件类型：【%s\n】", viper.SupportedExts)
 	}
 	//fmt.Printf("配置文件类型：【%s】", path.Ext(file))
 	v := viper.New()
-	//设置文件类型
-	v.SetConfigFile(path.Ext(file))
-	//监听文件变化
-	v.WatchConfig()
+	//设置文件(类型由后缀决定)
+	v.SetConfigFile(file)
 	if err = v.ReadConfig(bytes.NewBuffer(bts)); err != nil {
-		return err
+		return nil, err
 	}
 	//环境变量替换
 	if err = overrideEnv(v); err != nil {
-		return err
+		return nil, err
 	}
-	if viperConfMap == nil {
-		viperConfMap = make(map[string]*viper.Viper)
+	return v, nil
+}
+
+func getViper(name string) *viper.Viper {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return viperConfMap[name]
+}
+
+//注册配置文件变化回调，name为不带后缀的文件名，返回的函数用于取消注册
+func OnChange(fn func(name string)) (cancel func()) {
+	h := &changeHook{fn: fn}
+	changeMu.Lock()
+	changeHooks = append(changeHooks, h)
+	changeMu.Unlock()
+	return func() {
+		changeMu.Lock()
+		defer changeMu.Unlock()
+		//不能原地修改，回调执行时使用的是之前的切片
+		hooks := make([]*changeHook, 0, len(changeHooks))
+		for _, c := range changeHooks {
+			if c != h {
+				hooks = append(hooks, c)
+			}
+		}
+		changeHooks = hooks
 	}
-	filename := strings.TrimSuffix(path.Base(file), path.Ext(file))
-	viperConfMap[filename] = v
-	return nil
 }
 
 //获取字符串配置信息
@@ -71,8 +133,8 @@ func GetStringConf(key string) string {
 	if len(keys) < 2 {
 		return ""
 	}
-	v, ok := viperConfMap[keys[0]]
-	if !ok {
+	v := getViper(keys[0])
+	if v == nil {
 		return ""
 	}
 	confString := v.GetString(strings.Join(keys[1:len(keys)], "."))
@@ -85,7 +147,7 @@ func GetStringMapConf(key string) map[string]interface{} {
 	if len(keys) < 2 {
 		return nil
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetStringMap(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -96,7 +158,10 @@ func GetConf(key string) interface{} {
 	if len(keys) < 2 {
 		return nil
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
+	if v == nil {
+		return nil
+	}
 	conf := v.Get(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -107,7 +172,7 @@ func GetBoolConf(key string) bool {
 	if len(keys) < 2 {
 		return false
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetBool(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -118,7 +183,7 @@ func GetFloat64Conf(key string) float64 {
 	if len(keys) < 2 {
 		return 0
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetFloat64(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -129,7 +194,7 @@ func GetIntConf(key string) int {
 	if len(keys) < 2 {
 		return 0
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetInt(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -140,7 +205,10 @@ func GetStringMapStringConf(key string) map[string]string {
 	if len(keys) < 2 {
 		return nil
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
+	if v == nil {
+		return nil
+	}
 	conf := v.GetStringMapString(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -151,7 +219,7 @@ func GetStringSliceConf(key string) []string {
 	if len(keys) < 2 {
 		return nil
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetStringSlice(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -162,7 +230,7 @@ func GetTimeConf(key string) time.Time {
 	if len(keys) < 2 {
 		return time.Now()
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetTime(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -173,7 +241,7 @@ func GetDurationConf(key string) time.Duration {
 	if len(keys) < 2 {
 		return 0
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.GetDuration(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -184,7 +252,7 @@ func IsSetConf(key string) bool {
 	if len(keys) < 2 {
 		return false
 	}
-	v := viperConfMap[keys[0]]
+	v := getViper(keys[0])
 	conf := v.IsSet(strings.Join(keys[1:len(keys)], "."))
 	return conf
 }
@@ -0,0 +1,725 @@
+package dir
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat 归档格式
+type ArchiveFormat string
+
+const (
+	FormatTar    ArchiveFormat = "tar"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarZst ArchiveFormat = "tar.zst"
+	FormatZip    ArchiveFormat = "zip"
+)
+
+var (
+	ErrUnknownArchive  = errors.New("dir: unknown archive format")
+	ErrUnsafePath      = errors.New("dir: archive entry escapes destination")
+	ErrArchiveTooLarge = errors.New("dir: archive exceeds size limit")
+	ErrLinkNotAllowed  = errors.New("dir: archive contains a link")
+)
+
+// 解压的默认限制，防止压缩炸弹
+const (
+	DefaultExtractMaxSize  int64 = 4 << 30
+	DefaultExtractMaxFiles       = 1 << 20
+)
+
+// FormatFor 按扩展名判断归档格式，支持.tar .tar.gz .tgz .tar.zst .tzst .zip
+func FormatFor(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+		return FormatTarZst, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownArchive, name)
+}
+
+// sniffFormat 按文件头判断归档格式，gzip和zstd认为其中是tar
+func sniffFormat(r io.ReaderAt) (ArchiveFormat, error) {
+	head := make([]byte, 262)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return FormatTarZst, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return FormatTar, nil
+	}
+	return "", ErrUnknownArchive
+}
+
+// ArchiveProgress 每处理完一个条目报告一次
+type ArchiveProgress struct {
+	Path  string // 条目在归档中的路径
+	Files int    // 已处理的条目数
+	Bytes int64  // 已处理的文件内容字节数
+	Total int64  // 文件内容总字节数，未知时为0
+}
+
+// ArchiveOptions 打包选项
+type ArchiveOptions struct {
+	Include  []string      // 规则同WalkOptions
+	Exclude  []string      // 规则同WalkOptions
+	Symlinks SymlinkPolicy // 默认跳过符号链接，SymlinkInclude保存链接本身，SymlinkFollow保存指向的内容
+	Progress func(p ArchiveProgress)
+}
+
+// LinkPolicy 解压时对符号链接和硬链接的处理
+type LinkPolicy int
+
+const (
+	LinkWithin LinkPolicy = iota // 只创建指向目标目录内部的链接，指向外部时返回ErrUnsafePath
+	LinkSkip                     // 跳过链接
+	LinkReject                   // 遇到链接返回ErrLinkNotAllowed
+)
+
+// ExtractOptions 解压选项
+type ExtractOptions struct {
+	Format    ArchiveFormat // 为空时按扩展名判断，无法判断时读取文件头
+	MaxSize   int64         // 解压后的总字节数上限，默认DefaultExtractMaxSize，负数不限制
+	MaxFiles  int           // 条目数上限，默认DefaultExtractMaxFiles，负数不限制
+	Links     LinkPolicy
+	Overwrite bool // 覆盖已存在的文件，否则返回fs.ErrExist
+	Progress  func(p ArchiveProgress)
+}
+
+// Archive 把srcDir中的内容打包到dst，format为空时按dst的扩展名判断
+func Archive(srcDir, dst string, format ArchiveFormat) error {
+	return std.Archive(srcDir, dst, format)
+}
+
+func (h *Helper) Archive(srcDir, dst string, format ArchiveFormat) error {
+	return h.ArchiveWith(srcDir, dst, format, ArchiveOptions{})
+}
+
+// ArchiveWith 按opts打包，dst原子地写入，失败时不会留下不完整的文件
+func ArchiveWith(srcDir, dst string, format ArchiveFormat, opts ArchiveOptions) error {
+	return std.ArchiveWith(srcDir, dst, format, opts)
+}
+
+func (h *Helper) ArchiveWith(srcDir, dst string, format ArchiveFormat, opts ArchiveOptions) error {
+	if format == "" {
+		var err error
+		if format, err = FormatFor(dst); err != nil {
+			return err
+		}
+	}
+	entries, err := h.walkAll(srcDir, WalkOptions{
+		Include:     opts.Include,
+		Exclude:     opts.Exclude,
+		Symlinks:    opts.Symlinks,
+		IncludeDirs: true,
+	})
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range entries {
+		if e.Info.Mode().IsRegular() {
+			total += e.Info.Size()
+		}
+	}
+	out, err := h.NewAtomicWriter(dst, 0644)
+	if err != nil {
+		return err
+	}
+	aw, err := newArchiveWriter(out, format)
+	if err != nil {
+		out.Abort()
+		return err
+	}
+	progress := ArchiveProgress{Total: total}
+	for _, e := range entries {
+		n, err := h.addEntry(aw, e)
+		if err != nil {
+			aw.Close()
+			out.Abort()
+			return fmt.Errorf("dir: archive %s: %w", e.RelPath, err)
+		}
+		progress.Path = e.RelPath
+		progress.Files++
+		progress.Bytes += n
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Close()
+}
+
+func (h *Helper) addEntry(aw archiveWriter, e WalkEntry) (int64, error) {
+	mode := e.Info.Mode()
+	switch {
+	case mode.IsDir():
+		return 0, aw.add(e.RelPath+"/", e.Info, "", nil)
+	case mode&fs.ModeSymlink != 0:
+		target, err := readlink(h.fs, e.Path)
+		if err != nil {
+			return 0, err
+		}
+		return 0, aw.add(e.RelPath, e.Info, filepath.ToSlash(target), nil)
+	case mode.IsRegular():
+		f, err := h.fs.Open(e.Path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		cr := &countReader{r: f}
+		err = aw.add(e.RelPath, e.Info, "", cr)
+		return cr.n, err
+	}
+	// 设备文件、管道等不打包
+	return 0, nil
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archiveWriter 不同归档格式的写入
+type archiveWriter interface {
+	add(name string, info fs.FileInfo, link string, r io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	switch format {
+	case FormatTar:
+		return &tarWriter{tw: tar.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{tw: tar.NewWriter(gz), c: gz}, nil
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarWriter{tw: tar.NewWriter(zw), c: zw}, nil
+	case FormatZip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownArchive, format)
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	c  io.Closer // 压缩层
+}
+
+func (t *tarWriter) add(name string, info fs.FileInfo, link string, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	// 不保存本机的用户名，归档在不同机器上保持一致
+	hdr.Uname, hdr.Gname = "", ""
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	n, err := io.Copy(t.tw, r)
+	if err == nil && n != hdr.Size {
+		err = fmt.Errorf("file changed during archiving: size %d, read %d", hdr.Size, n)
+	}
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	err := t.tw.Close()
+	if t.c != nil {
+		if cerr := t.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) add(name string, info fs.FileInfo, link string, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.Mode().IsRegular() {
+		hdr.Method = zip.Deflate
+	}
+	w, err := z.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if link != "" {
+		// zip中符号链接的内容为链接目标
+		_, err = io.WriteString(w, link)
+		return err
+	}
+	if r != nil {
+		_, err = io.Copy(w, r)
+	}
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
+
+// Extract 解压src到dstDir，格式按扩展名或文件头判断
+func Extract(src, dstDir string) error {
+	return std.Extract(src, dstDir)
+}
+
+func (h *Helper) Extract(src, dstDir string) error {
+	return h.ExtractWith(src, dstDir, ExtractOptions{})
+}
+
+// ExtractWith 按opts解压，拒绝绝对路径和越过dstDir的条目，超过大小限制时停止并返回ErrArchiveTooLarge
+// 出错时已解压的文件保留
+func ExtractWith(src, dstDir string, opts ExtractOptions) error {
+	return std.ExtractWith(src, dstDir, opts)
+}
+
+func (h *Helper) ExtractWith(src, dstDir string, opts ExtractOptions) error {
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultExtractMaxSize
+	}
+	if opts.MaxFiles == 0 {
+		opts.MaxFiles = DefaultExtractMaxFiles
+	}
+	f, err := h.fs.OpenFile(src, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	format := opts.Format
+	if format == "" {
+		if format, err = FormatFor(src); err != nil {
+			if format, err = sniffFormat(f); err != nil {
+				return fmt.Errorf("%w: %s", ErrUnknownArchive, src)
+			}
+		}
+	}
+	if err := h.fs.MkdirAll(dstDir, os.ModePerm); err != nil {
+		return err
+	}
+	root, err := evalSymlinks(h.fs, dstDir)
+	if err != nil {
+		return err
+	}
+	x := &extractor{h: h, dst: filepath.Clean(dstDir), root: root, opts: opts}
+	switch format {
+	case FormatZip:
+		var info fs.FileInfo
+		if info, err = f.Stat(); err == nil {
+			err = x.zip(f, info.Size())
+		}
+	case FormatTar, FormatTarGz, FormatTarZst:
+		err = x.tar(f, format)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownArchive, format)
+	}
+	if err != nil {
+		return err
+	}
+	// 目录的修改时间在其中的文件写完后再设置，从最深的目录开始
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		_ = h.fs.Chtimes(x.dirs[i].path, x.dirs[i].mtime, x.dirs[i].mtime)
+	}
+	return nil
+}
+
+type extractor struct {
+	h        *Helper
+	dst      string
+	root     string // dst解析符号链接后的路径
+	opts     ExtractOptions
+	progress ArchiveProgress
+	dirs     []dirTime
+}
+
+type dirTime struct {
+	path  string
+	mtime time.Time
+}
+
+// entry 归档中一个条目的统一表示
+type entry struct {
+	name     string
+	mode     fs.FileMode
+	size     int64
+	mtime    time.Time
+	link     string // 符号链接的目标
+	hardlink string // 硬链接指向的归档内路径
+	r        io.Reader
+}
+
+func (x *extractor) tar(f io.Reader, format ArchiveFormat) error {
+	r := f
+	switch format {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case FormatTarZst:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := entry{name: hdr.Name, mode: hdr.FileInfo().Mode(), size: hdr.Size, mtime: hdr.ModTime, r: tr}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeRegA:
+		case tar.TypeSymlink:
+			e.link = hdr.Linkname
+		case tar.TypeLink:
+			e.hardlink = hdr.Linkname
+		default:
+			// 设备文件、管道和扩展头不解压
+			continue
+		}
+		if err := x.extract(e); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zip(f io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		x.progress.Total += int64(zf.UncompressedSize64)
+	}
+	for _, zf := range zr.File {
+		if err := x.zipEntry(zf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) zipEntry(zf *zip.File) error {
+	mode := zf.Mode()
+	e := entry{name: zf.Name, mode: mode, size: int64(zf.UncompressedSize64), mtime: zf.Modified}
+	if mode.IsDir() {
+		return x.extract(e)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if mode&fs.ModeSymlink != 0 {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		e.link = string(target)
+	} else if !mode.IsRegular() {
+		return nil
+	}
+	e.r = rc
+	return x.extract(e)
+}
+
+// target 条目在dst中的路径，拒绝绝对路径和..
+func (x *extractor) target(name string) (string, error) {
+	if !safeRelPath(name) {
+		return "", &fs.PathError{Op: "extract", Path: name, Err: ErrUnsafePath}
+	}
+	return filepath.Join(x.dst, filepath.FromSlash(path.Clean(strings.ReplaceAll(name, `\`, "/")))), nil
+}
+
+// safeRelPath name是否为不含..、不带盘符的相对路径，\按/处理
+func safeRelPath(name string) bool {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if slashed == "" || path.IsAbs(slashed) || filepath.VolumeName(name) != "" {
+		return false
+	}
+	for _, seg := range strings.Split(slashed, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// within 检查p最近的已存在的上级目录解析符号链接后仍在dst中，防止通过之前解压或已存在的链接写到外部
+func (x *extractor) within(p string) error {
+	dir := filepath.Dir(p)
+	for {
+		resolved, err := evalSymlinks(x.h.fs, dir)
+		if err == nil {
+			if !x.inRoot(resolved) {
+				return &fs.PathError{Op: "extract", Path: p, Err: ErrUnsafePath}
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) || dir == x.dst {
+			return err
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// inRoot 已解析符号链接的路径是否在dst中
+func (x *extractor) inRoot(resolved string) bool {
+	return inRoot(x.root, resolved)
+}
+
+// linkWithin 检查p处的符号链接指向dst中，target相对链接所在目录
+func (x *extractor) linkWithin(p, target string) error {
+	ok, err := linkWithin(x.h.fs, x.root, filepath.Dir(p), target)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &fs.PathError{Op: "extract", Path: p, Err: ErrUnsafePath}
+	}
+	return nil
+}
+
+// inRoot 已解析符号链接的路径是否在root中
+func inRoot(root, resolved string) bool {
+	return resolved == root || strings.HasPrefix(resolved, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// linkWithin dir中指向target的符号链接是否指向root中，root已解析符号链接
+// 按操作系统的规则逐段解析，已存在的链接也会跟随，防止a -> .、a/b -> ..这类链式链接越过root
+func linkWithin(fsys FS, root, dir, target string) (bool, error) {
+	target = strings.ReplaceAll(target, `\`, "/")
+	if path.IsAbs(target) || filepath.VolumeName(target) != "" {
+		return false, nil
+	}
+	cur, err := evalSymlinks(fsys, dir)
+	if err != nil {
+		return false, err
+	}
+	missing := false
+	for _, seg := range strings.Split(target, "/") {
+		switch {
+		case seg == "" || seg == ".":
+			continue
+		case seg == ".." && missing:
+			// 不存在的路径之后可能被创建为链接，..的结果无法确定
+			return false, nil
+		case seg == "..":
+			cur = filepath.Dir(cur)
+			continue
+		}
+		cur = filepath.Join(cur, seg)
+		if missing {
+			continue
+		}
+		info, err := fsys.Lstat(cur)
+		if os.IsNotExist(err) {
+			missing = true
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			// 中间的链接无法解析时同样无法确定指向
+			if cur, err = evalSymlinks(fsys, cur); err != nil {
+				return false, nil
+			}
+		}
+		if !inRoot(root, cur) {
+			return false, nil
+		}
+	}
+	return inRoot(root, cur), nil
+}
+
+func (x *extractor) extract(e entry) error {
+	x.progress.Files++
+	if x.opts.MaxFiles > 0 && x.progress.Files > x.opts.MaxFiles {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, x.opts.MaxFiles)
+	}
+	p, err := x.target(e.name)
+	if err != nil {
+		return err
+	}
+	isLink := e.link != "" || e.hardlink != ""
+	if isLink {
+		switch x.opts.Links {
+		case LinkSkip:
+			return nil
+		case LinkReject:
+			return &fs.PathError{Op: "extract", Path: e.name, Err: ErrLinkNotAllowed}
+		}
+	}
+	if err := x.within(p); err != nil {
+		return err
+	}
+	if err := x.h.fs.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	if e.mode.IsDir() {
+		if err := x.h.fs.MkdirAll(p, e.mode.Perm()|0700); err != nil {
+			return err
+		}
+		x.dirs = append(x.dirs, dirTime{path: p, mtime: e.mtime})
+		x.report(e.name)
+		return nil
+	}
+	if err := x.prepare(p); err != nil {
+		return err
+	}
+	switch {
+	case e.link != "":
+		if err := x.linkWithin(p, e.link); err != nil {
+			return err
+		}
+		if err := symlink(x.h.fs, filepath.FromSlash(e.link), p); err != nil {
+			return err
+		}
+		x.report(e.name)
+		return nil
+	case e.hardlink != "":
+		// 硬链接复制已解压的目标文件的内容
+		src, err := x.target(e.hardlink)
+		if err != nil {
+			return err
+		}
+		if err := x.within(src); err != nil {
+			return err
+		}
+		// 不跟随符号链接，链接可能指向dst外部
+		info, err := x.h.fs.Lstat(src)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return &fs.PathError{Op: "extract", Path: e.name, Err: ErrUnsafePath}
+		}
+		f, err := x.h.fs.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		e.r, e.mode, e.mtime = f, info.Mode(), info.ModTime()
+	}
+	return x.writeFile(p, e)
+}
+
+// prepare 处理已存在的路径，符号链接总是先删除，避免写入链接指向的文件
+func (x *extractor) prepare(p string) error {
+	info, err := x.h.fs.Lstat(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !x.opts.Overwrite {
+		return &fs.PathError{Op: "extract", Path: p, Err: fs.ErrExist}
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "extract", Path: p, Err: errIsDir}
+	}
+	return x.h.fs.Remove(p)
+}
+
+func (x *extractor) writeFile(p string, e entry) error {
+	remaining := int64(-1)
+	if x.opts.MaxSize > 0 {
+		remaining = x.opts.MaxSize - x.progress.Bytes
+		if e.size > remaining {
+			return fmt.Errorf("%w: %s needs %d bytes, %d left", ErrArchiveTooLarge, e.name, e.size, remaining)
+		}
+	}
+	f, err := x.h.fs.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, e.mode.Perm())
+	if err != nil {
+		return err
+	}
+	r := e.r
+	if remaining >= 0 {
+		// 头部记录的大小可能是假的，按实际读取的字节数限制
+		r = io.LimitReader(r, remaining+1)
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	x.progress.Bytes += n
+	if err == nil && remaining >= 0 && n > remaining {
+		err = fmt.Errorf("%w: %s", ErrArchiveTooLarge, e.name)
+	}
+	if err != nil {
+		_ = x.h.fs.Remove(p)
+		return err
+	}
+	_ = x.h.fs.Chtimes(p, e.mtime, e.mtime)
+	x.report(e.name)
+	return nil
+}
+
+func (x *extractor) report(name string) {
+	if x.opts.Progress != nil {
+		p := x.progress
+		p.Path = name
+		x.opts.Progress(p)
+	}
+}
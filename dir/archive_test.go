@@ -0,0 +1,229 @@
+package dir
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTar 构造测试用的tar，link不为空时为符号链接，以=开头时为硬链接
+func writeTar(t *testing.T, p string, entries ...[3]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		name, body, link := e[0], e[1], e[2]
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg, ModTime: time.Now()}
+		switch {
+		case strings.HasPrefix(link, "="):
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, link[1:], 0
+		case link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, link, 0
+		case strings.HasSuffix(name, "/"):
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte(body))
+		}
+	}
+	tw.Close()
+	if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	src := t.TempDir()
+	makeTree(t, src, map[string]string{
+		"a.txt":         "hello",
+		"sub/b.txt":     strings.Repeat("b", 100000),
+		"sub/deep/c.go": "package c",
+		"empty/":        "",
+		"skip.log":      "x",
+	})
+	os.Symlink("sub/b.txt", filepath.Join(src, "link"))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "a.txt"), mtime, mtime)
+
+	for _, format := range []ArchiveFormat{FormatTar, FormatTarGz, FormatTarZst, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "bundle."+string(format))
+			var last ArchiveProgress
+			err := ArchiveWith(src, dst, "", ArchiveOptions{
+				Exclude:  []string{"*.log"},
+				Symlinks: SymlinkInclude,
+				Progress: func(p ArchiveProgress) { last = p },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last.Files != 7 || last.Bytes != 100014 || last.Total != 100014 {
+				t.Fatalf("progress = %+v", last)
+			}
+			out := t.TempDir()
+			var files int
+			if err := ExtractWith(dst, out, ExtractOptions{Progress: func(p ArchiveProgress) { files = p.Files }}); err != nil {
+				t.Fatal(err)
+			}
+			if files != 7 {
+				t.Fatalf("extract progress files = %d", files)
+			}
+			got, want := walkRel(t, out, WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude}), walkRel(t, src, WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude, Exclude: []string{"*.log"}})
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("extracted %v, want %v", got, want)
+			}
+			if s, _ := ReadString(filepath.Join(out, "link")); len(s) != 100000 {
+				t.Fatalf("link content length %d", len(s))
+			}
+			if target, _ := os.Readlink(filepath.Join(out, "link")); target != "sub/b.txt" {
+				t.Fatalf("link target %q", target)
+			}
+			if info, _ := os.Stat(filepath.Join(out, "a.txt")); !info.ModTime().Equal(mtime) {
+				t.Fatalf("mtime = %v", info.ModTime())
+			}
+		})
+	}
+}
+
+func TestArchiveSniffAndOverwrite(t *testing.T) {
+	src := t.TempDir()
+	makeTree(t, src, map[string]string{"a.txt": "1"})
+	for _, format := range []ArchiveFormat{FormatTar, FormatTarGz, FormatTarZst, FormatZip} {
+		dst := filepath.Join(t.TempDir(), "bundle.bin")
+		if err := Archive(src, dst, format); err != nil {
+			t.Fatal(err)
+		}
+		out := t.TempDir()
+		if err := Extract(dst, out); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if err := Extract(dst, out); !errors.Is(err, fs.ErrExist) {
+			t.Fatalf("%s: err = %v, want ErrExist", format, err)
+		}
+		os.WriteFile(filepath.Join(out, "a.txt"), []byte("changed"), 0644)
+		if err := ExtractWith(dst, out, ExtractOptions{Overwrite: true}); err != nil {
+			t.Fatal(err)
+		}
+		if s, _ := ReadString(filepath.Join(out, "a.txt")); s != "1" {
+			t.Fatalf("%s: a.txt = %q", format, s)
+		}
+	}
+	if err := Archive(src, filepath.Join(t.TempDir(), "x.rar"), ""); !errors.Is(err, ErrUnknownArchive) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	base := t.TempDir()
+	outside := filepath.Join(base, "outside")
+	os.Mkdir(outside, 0755)
+	cases := []struct {
+		name    string
+		entries [][3]string
+		opts    ExtractOptions
+		want    error
+	}{
+		{"dotdot", [][3]string{{"ok.txt", "1", ""}, {"../evil.txt", "x", ""}}, ExtractOptions{}, ErrUnsafePath},
+		{"nested dotdot", [][3]string{{"a/../../evil.txt", "x", ""}}, ExtractOptions{}, ErrUnsafePath},
+		{"absolute", [][3]string{{"/tmp/evil.txt", "x", ""}}, ExtractOptions{}, ErrUnsafePath},
+		{"abs symlink", [][3]string{{"l", "", outside}}, ExtractOptions{}, ErrUnsafePath},
+		{"rel symlink", [][3]string{{"d/l", "", "../../outside"}}, ExtractOptions{}, ErrUnsafePath},
+		{"reject link", [][3]string{{"l", "", "a"}}, ExtractOptions{Links: LinkReject}, ErrLinkNotAllowed},
+		{"skip link", [][3]string{{"l", "", outside}, {"ok.txt", "1", ""}}, ExtractOptions{Links: LinkSkip}, nil},
+		{"hardlink out", [][3]string{{"h", "", "=../outside/x"}}, ExtractOptions{}, ErrUnsafePath},
+		{"hardlink", [][3]string{{"a.txt", "data", ""}, {"h", "", "=a.txt"}}, ExtractOptions{}, nil},
+		{"chained symlink", [][3]string{{"a", "", "."}, {"a/b", "", ".."}, {"s", "", "a/b/secret"}, {"copy", "", "=s"}}, ExtractOptions{}, ErrUnsafePath},
+		{"dotdot after symlink", [][3]string{{"b", "", "."}, {"s", "", "b/../outside"}}, ExtractOptions{}, ErrUnsafePath},
+		{"dotdot after missing", [][3]string{{"s", "", "n/../x"}}, ExtractOptions{}, ErrUnsafePath},
+		{"hardlink to symlink", [][3]string{{"f.txt", "1", ""}, {"l", "", "f.txt"}, {"h", "", "=l"}}, ExtractOptions{}, ErrUnsafePath},
+		{"symlink chain inside", [][3]string{{"sub/f.txt", "1", ""}, {"a", "", "sub"}, {"b", "", "a/f.txt"}}, ExtractOptions{}, nil},
+		{"inside symlink", [][3]string{{"sub/", "", ""}, {"d", "", "sub"}, {"d/f.txt", "1", ""}}, ExtractOptions{}, nil},
+		{"too large", [][3]string{{"a.txt", strings.Repeat("x", 100), ""}}, ExtractOptions{MaxSize: 99}, ErrArchiveTooLarge},
+		{"too many", [][3]string{{"a", "", ""}, {"b", "", ""}, {"c", "", ""}}, ExtractOptions{MaxFiles: 2}, ErrArchiveTooLarge},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "x.tar")
+			writeTar(t, p, c.entries...)
+			out := filepath.Join(base, "out-"+strings.ReplaceAll(c.name, " ", "-"))
+			err := ExtractWith(p, out, c.opts)
+			if c.want == nil && err != nil || c.want != nil && !errors.Is(err, c.want) {
+				t.Fatalf("err = %v, want %v", err, c.want)
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Fatalf("wrote outside: %v", entries)
+			}
+			if IsExist(filepath.Join(base, "evil.txt")) || IsExist("/tmp/evil.txt") {
+				t.Fatal("wrote outside")
+			}
+		})
+	}
+
+	// 目标目录中已存在的指向外部的链接
+	out := t.TempDir()
+	os.Symlink(outside, filepath.Join(out, "out"))
+	p := filepath.Join(t.TempDir(), "x.tar")
+	writeTar(t, p, [3]string{"out/new/x.txt", "x", ""})
+	if err := Extract(p, out); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("err = %v", err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("wrote outside: %v", entries)
+	}
+	// 已存在的链接被覆盖时不写入链接指向的文件
+	os.WriteFile(filepath.Join(outside, "target"), []byte("keep"), 0644)
+	os.Symlink(filepath.Join(outside, "target"), filepath.Join(out, "f"))
+	writeTar(t, p, [3]string{"f", "new", ""})
+	if err := ExtractWith(p, out, ExtractOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := ReadString(filepath.Join(outside, "target")); s != "keep" {
+		t.Fatalf("outside target = %q", s)
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create(`..\..\evil.txt`)
+	w.Write([]byte("x"))
+	zw.Close()
+	p := filepath.Join(t.TempDir(), "x.zip")
+	os.WriteFile(p, buf.Bytes(), 0644)
+	if err := Extract(p, filepath.Join(t.TempDir(), "out")); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestArchiveOnMemFS(t *testing.T) {
+	h := NewHelper(NewMemFS())
+	h.FS().MkdirAll("/src/sub", 0755)
+	h.FS().WriteFile("/src/sub/a.txt", []byte("mem"), 0644)
+	if err := h.Archive("/src", "/b.tar.zst", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Extract("/b.tar.zst", "/out"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := h.FS().ReadFile("/out/sub/a.txt"); err != nil || string(data) != "mem" {
+		t.Fatalf("data = %q, err = %v", data, err)
+	}
+}
@@ -0,0 +1,189 @@
+package dir
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAtomicWriterClosed = errors.New("dir: atomic writer already closed")
+
+	tmpRandMu sync.Mutex
+	tmpRand   = rand.New(rand.NewSource(time.Now().UnixNano() + int64(os.Getpid())))
+)
+
+// AtomicWriter 先写同目录下的临时文件，Close时fsync并rename为目标文件
+// 写入过程中程序崩溃或出错，目标文件保持原样；目标文件已存在时保留原权限和属主
+type AtomicWriter struct {
+	fs     FS
+	f      File
+	path   string // 目标文件，符号链接已解析为实际文件
+	mode   os.FileMode
+	keep   bool // 目标已存在，需要恢复权限和属主
+	info   os.FileInfo
+	err    error
+	closed bool
+}
+
+// NewAtomicWriter 创建目录(如果不存在)和临时文件，perm只在目标文件不存在时使用，受umask影响
+func NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	return std.NewAtomicWriter(path, perm)
+}
+
+// NewAtomicWriter 在h的文件系统中原子写入，只有OS会fsync目录和恢复属主
+func (h *Helper) NewAtomicWriter(path string, perm os.FileMode) (*AtomicWriter, error) {
+	target, err := resolveTarget(h.fs, path)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(target)
+	if err = h.fs.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	w := &AtomicWriter{fs: h.fs, path: target, mode: perm}
+	if info, err := h.fs.Stat(target); err == nil {
+		if info.IsDir() {
+			return nil, &os.PathError{Op: "write", Path: path, Err: errors.New("is a directory")}
+		}
+		w.keep, w.info, w.mode = true, info, info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if w.f, err = createTemp(h.fs, dir, filepath.Base(target), perm); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// resolveTarget 目标是符号链接时写入链接指向的文件，链接本身保持不变
+func resolveTarget(fsys FS, path string) (string, error) {
+	info, err := fsys.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return path, nil
+	}
+	target, err := evalSymlinks(fsys, path)
+	if os.IsNotExist(err) {
+		// 悬空的链接，写入链接指向的路径
+		link, err := readlink(fsys, path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		return link, nil
+	}
+	return target, err
+}
+
+// createTemp 与os.CreateTemp不同，新建文件的权限使用perm并受umask影响
+func createTemp(fsys FS, dir, base string, perm os.FileMode) (File, error) {
+	for i := 0; i < 10000; i++ {
+		tmpRandMu.Lock()
+		suffix := strconv.FormatUint(uint64(tmpRand.Uint32()), 36)
+		tmpRandMu.Unlock()
+		name := filepath.Join(dir, "."+base+".tmp-"+suffix)
+		f, err := fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, "."+base+".tmp-*"), Err: os.ErrExist}
+}
+
+// Name 临时文件路径
+func (w *AtomicWriter) Name() string {
+	return w.f.Name()
+}
+
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrAtomicWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.f.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Close 提交写入：fsync临时文件、恢复权限和属主、rename、fsync目录
+// 之前的Write出错时放弃写入并返回该错误
+func (w *AtomicWriter) Close() error {
+	if w.closed {
+		return ErrAtomicWriterClosed
+	}
+	if w.err != nil {
+		w.Abort()
+		return w.err
+	}
+	w.closed = true
+	err := w.commit()
+	if err != nil {
+		_ = w.f.Close()
+		_ = w.fs.Remove(w.f.Name())
+	}
+	return err
+}
+
+func (w *AtomicWriter) commit() error {
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	if w.keep {
+		if err := w.fs.Chmod(w.f.Name(), w.mode); err != nil {
+			return err
+		}
+		// 属主只有root或者文件原属主本身能修改，失败时忽略
+		if f, ok := w.f.(*os.File); ok {
+			_ = chownLike(f, w.info)
+		}
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if err := w.fs.Rename(w.f.Name(), w.path); err != nil {
+		return err
+	}
+	if !isOS(w.fs) {
+		return nil
+	}
+	return syncDir(filepath.Dir(w.path))
+}
+
+// Abort 放弃写入并删除临时文件，Close之后调用无效
+func (w *AtomicWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	_ = w.f.Close()
+	_ = w.fs.Remove(w.f.Name())
+}
+
+// WriteFileAtomic 原子地替换文件内容：写同目录临时文件、fsync、rename、fsync目录
+// 目标文件已存在时保留原权限和属主，否则使用perm(受umask影响)
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return std.WriteFileAtomic(path, data, perm)
+}
+
+func (h *Helper) WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	w, err := h.NewAtomicWriter(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
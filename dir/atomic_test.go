@@ -0,0 +1,93 @@
+package dir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	d := t.TempDir()
+	p := filepath.Join(d, "sub", "conf.yaml")
+	if err := WriteFileAtomic(p, []byte("a: 1\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if bs, _ := os.ReadFile(p); string(bs) != "a: 1\n" {
+		t.Fatalf("content = %q", bs)
+	}
+
+	// 替换已存在的文件时保留原权限
+	if err := os.Chmod(p, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(p, []byte("a: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(p)
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v", info.Mode())
+	}
+	if bs, _ := os.ReadFile(p); string(bs) != "a: 2\n" {
+		t.Fatalf("content = %q", bs)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(p)); len(entries) != 1 {
+		t.Fatalf("temp files left: %v", entries)
+	}
+}
+
+func TestAtomicWriterAbort(t *testing.T) {
+	d := t.TempDir()
+	p := filepath.Join(d, "state.json")
+	if _, err := WriteString(p, "old"); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewAtomicWriter(p, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("partial"))
+	// 模拟写到一半失败
+	w.Abort()
+	if bs, _ := os.ReadFile(p); string(bs) != "old" {
+		t.Fatalf("content = %q", bs)
+	}
+	if _, err := os.Stat(w.Name()); !os.IsNotExist(err) {
+		t.Fatal("temp file should be removed")
+	}
+	if err := w.Close(); !errors.Is(err, ErrAtomicWriterClosed) {
+		t.Fatalf("Close after Abort = %v", err)
+	}
+}
+
+func TestAtomicWriterSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink")
+	}
+	d := t.TempDir()
+	target := filepath.Join(d, "target.txt")
+	link := filepath.Join(d, "link.txt")
+	_ = os.WriteFile(target, []byte("old"), 0644)
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(link, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink should be kept")
+	}
+	if bs, _ := os.ReadFile(target); string(bs) != "new" {
+		t.Fatalf("target content = %q", bs)
+	}
+}
+
+func TestWriteBytesMkdirError(t *testing.T) {
+	d := t.TempDir()
+	blocker := filepath.Join(d, "file")
+	_ = os.WriteFile(blocker, nil, 0644)
+	if _, err := WriteBytes(filepath.Join(blocker, "x", "y.txt"), []byte("z")); err == nil {
+		t.Fatal("expected error when parent is a file")
+	}
+}
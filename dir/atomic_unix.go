@@ -0,0 +1,41 @@
+//go:build !windows
+
+package dir
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike 临时文件的属主改为原文件的属主
+func chownLike(f *os.File, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(st.Uid) == os.Geteuid() && int(st.Gid) == os.Getegid() {
+		return nil
+	}
+	return f.Chown(int(st.Uid), int(st.Gid))
+}
+
+// syncDir rename之后fsync目录，保证目录项落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil && !isSyncUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
+// isSyncUnsupported 部分文件系统不支持对目录fsync
+func isSyncUnsupported(err error) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == syscall.EINVAL || err == syscall.ENOTSUP
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package dir
+
+import "os"
+
+// chownLike windows没有uid/gid
+func chownLike(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir windows不支持对目录fsync，rename(MoveFileEx)本身是持久的
+func syncDir(dir string) error {
+	return nil
+}
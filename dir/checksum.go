@@ -0,0 +1,325 @@
+package dir
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgo 哈希算法名称
+type HashAlgo string
+
+const (
+	HashMD5    HashAlgo = "md5"
+	HashSHA1   HashAlgo = "sha1"
+	HashSHA256 HashAlgo = "sha256"
+	HashSHA512 HashAlgo = "sha512"
+	HashCRC32C HashAlgo = "crc32c"
+	HashXXHash HashAlgo = "xxhash" // xxh64
+)
+
+var ErrUnknownHash = errors.New("dir: unknown hash algorithm")
+
+var (
+	hashMu    sync.RWMutex
+	hashFuncs = map[HashAlgo]func() hash.Hash{
+		HashMD5:    md5.New,
+		HashSHA1:   sha1.New,
+		HashSHA256: sha256.New,
+		HashSHA512: sha512.New,
+		HashCRC32C: func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+		HashXXHash: func() hash.Hash { return xxhash.New() },
+	}
+)
+
+// RegisterHash 注册哈希算法，已存在时覆盖
+func RegisterHash(algo HashAlgo, fn func() hash.Hash) {
+	hashMu.Lock()
+	defer hashMu.Unlock()
+	hashFuncs[algo] = fn
+}
+
+// NewHash 创建algo对应的hash.Hash
+func NewHash(algo HashAlgo) (hash.Hash, error) {
+	hashMu.RLock()
+	fn, ok := hashFuncs[algo]
+	hashMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHash, algo)
+	}
+	return fn(), nil
+}
+
+// Checksum 计算文件的哈希，返回十六进制字符串
+func Checksum(file string, algo HashAlgo) (string, error) {
+	return std.Checksum(file, algo)
+}
+
+func (h *Helper) Checksum(file string, algo HashAlgo) (string, error) {
+	sums, err := h.MultiChecksum(file, algo)
+	if err != nil {
+		return "", err
+	}
+	return sums[algo], nil
+}
+
+// MultiChecksum 读一遍文件同时计算多种哈希
+func MultiChecksum(file string, algos ...HashAlgo) (map[HashAlgo]string, error) {
+	return std.MultiChecksum(file, algos...)
+}
+
+func (h *Helper) MultiChecksum(file string, algos ...HashAlgo) (map[HashAlgo]string, error) {
+	f, err := h.fs.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ChecksumReader(f, algos...)
+}
+
+// ChecksumReader 读完r同时计算多种哈希
+func ChecksumReader(r io.Reader, algos ...HashAlgo) (map[HashAlgo]string, error) {
+	hashes := make([]hash.Hash, len(algos))
+	writers := make([]io.Writer, len(algos))
+	for i, algo := range algos {
+		h, err := NewHash(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i], writers[i] = h, h
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), bufio.NewReader(r)); err != nil {
+		return nil, err
+	}
+	sums := make(map[HashAlgo]string, len(algos))
+	for i, algo := range algos {
+		sums[algo] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// ChecksumResult ChecksumFiles的结果
+type ChecksumResult struct {
+	Path string
+	Sum  string
+	Err  error
+}
+
+// ChecksumFiles 用workers个goroutine并行计算多个文件的哈希，结果顺序与paths相同
+// workers<=0时使用CPU数，ctx取消后未计算的文件Err为ctx.Err()
+func ChecksumFiles(ctx context.Context, paths []string, algo HashAlgo, workers int) []ChecksumResult {
+	return std.ChecksumFiles(ctx, paths, algo, workers)
+}
+
+func (h *Helper) ChecksumFiles(ctx context.Context, paths []string, algo HashAlgo, workers int) []ChecksumResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]ChecksumResult, len(paths))
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				sum, err := h.Checksum(paths[i], algo)
+				results[i] = ChecksumResult{Path: paths[i], Sum: sum, Err: err}
+			}
+		}()
+	}
+	i := 0
+loop:
+	for ; i < len(paths) && ctx.Err() == nil; i++ {
+		select {
+		case idx <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(idx)
+	wg.Wait()
+	for ; i < len(paths); i++ {
+		results[i] = ChecksumResult{Path: paths[i], Err: ctx.Err()}
+	}
+	return results
+}
+
+// GenerateManifest 计算root下所有文件的哈希，按sha256sum的格式("<hash>  <相对路径>")写入w
+// 路径使用/分隔并按字典序排列，opts可为nil
+func GenerateManifest(ctx context.Context, root string, algo HashAlgo, opts *WalkOptions, w io.Writer) error {
+	return std.GenerateManifest(ctx, root, algo, opts, w)
+}
+
+func (h *Helper) GenerateManifest(ctx context.Context, root string, algo HashAlgo, opts *WalkOptions, w io.Writer) error {
+	var o WalkOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.IncludeDirs = false
+	var rels, paths []string
+	if err := h.Walk(root, o, func(e WalkEntry) error {
+		if e.Info.Mode().IsRegular() {
+			rels = append(rels, e.RelPath)
+			paths = append(paths, e.Path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	results := h.ChecksumFiles(ctx, paths, algo, o.Workers)
+	bw := bufio.NewWriter(w)
+	for i, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+		bw.WriteString(manifestLine(r.Sum, rels[i]))
+	}
+	return bw.Flush()
+}
+
+// manifestLine 文件名含\或换行时与coreutils一样在行首加\并转义
+func manifestLine(sum, name string) string {
+	if strings.ContainsAny(name, "\\\n\r") {
+		r := strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+		return "\\" + sum + "  " + r.Replace(name) + "\n"
+	}
+	return sum + "  " + name + "\n"
+}
+
+// ManifestStatus 校验结果
+type ManifestStatus string
+
+const (
+	ManifestOK       ManifestStatus = "OK"
+	ManifestMismatch ManifestStatus = "FAILED"
+	ManifestMissing  ManifestStatus = "MISSING"
+)
+
+// ManifestResult 清单中一个文件的校验结果
+type ManifestResult struct {
+	Path     string
+	Status   ManifestStatus
+	Expected string
+	Actual   string
+	Err      error // 文件不存在、读取失败或路径不安全(ErrUnsafePath)
+}
+
+// VerifyManifest 读取sha256sum格式的清单，校验root下对应的文件
+// 清单格式错误时返回带行号的错误，文件不一致不算错误，通过结果的Status判断
+// 绝对路径和含..的文件名不会读取，结果为ManifestMissing，Err为ErrUnsafePath
+func VerifyManifest(ctx context.Context, root string, algo HashAlgo, r io.Reader, workers int) ([]ManifestResult, error) {
+	return std.VerifyManifest(ctx, root, algo, r, workers)
+}
+
+func (h *Helper) VerifyManifest(ctx context.Context, root string, algo HashAlgo, r io.Reader, workers int) ([]ManifestResult, error) {
+	var expected []ManifestResult
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		sum, name, err := parseManifestLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("dir: manifest line %d: %w", line, err)
+		}
+		expected = append(expected, ManifestResult{Path: name, Expected: strings.ToLower(sum)})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	// 只校验root中的文件
+	var paths []string
+	var index []int
+	for i := range expected {
+		e := &expected[i]
+		if !safeRelPath(e.Path) {
+			e.Status, e.Err = ManifestMissing, &fs.PathError{Op: "verify", Path: e.Path, Err: ErrUnsafePath}
+			continue
+		}
+		paths = append(paths, filepath.Join(root, filepath.FromSlash(e.Path)))
+		index = append(index, i)
+	}
+	for i, res := range h.ChecksumFiles(ctx, paths, algo, workers) {
+		e := &expected[index[i]]
+		switch {
+		case res.Err != nil:
+			e.Status, e.Err = ManifestMissing, res.Err
+		case res.Sum == e.Expected:
+			e.Status, e.Actual = ManifestOK, res.Sum
+		default:
+			e.Status, e.Actual = ManifestMismatch, res.Sum
+		}
+	}
+	return expected, nil
+}
+
+// parseManifestLine 支持文本模式("  ")和二进制模式(" *")
+func parseManifestLine(line string) (sum, name string, err error) {
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+	i := strings.IndexByte(line, ' ')
+	if i <= 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+		return "", "", errors.New("invalid format")
+	}
+	sum, name = line[:i], line[i+2:]
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", fmt.Errorf("invalid checksum %q", sum)
+	}
+	if escaped {
+		name = unescapeManifestName(name)
+	}
+	return sum, name, nil
+}
+
+func unescapeManifestName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// HashAlgos 返回已注册的算法，按名称排序
+func HashAlgos() []HashAlgo {
+	hashMu.RLock()
+	defer hashMu.RUnlock()
+	algos := make([]HashAlgo, 0, len(hashFuncs))
+	for algo := range hashFuncs {
+		algos = append(algos, algo)
+	}
+	sort.Slice(algos, func(i, j int) bool { return algos[i] < algos[j] })
+	return algos
+}
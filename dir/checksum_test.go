@@ -0,0 +1,188 @@
+package dir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(p, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := map[HashAlgo]string{
+		HashMD5:    "5d41402abc4b2a76b9719d911017c592",
+		HashSHA1:   "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		HashSHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		HashSHA512: "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043",
+		HashCRC32C: "9a71bb4c",
+		HashXXHash: "26c7827d889f6da3",
+	}
+	for algo, sum := range want {
+		got, err := Checksum(p, algo)
+		if err != nil || got != sum {
+			t.Errorf("Checksum(%s) = %s, %v, want %s", algo, got, err, sum)
+		}
+	}
+	algos := []HashAlgo{HashMD5, HashSHA256, HashXXHash}
+	sums, err := MultiChecksum(p, algos...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, algo := range algos {
+		if sums[algo] != want[algo] {
+			t.Errorf("MultiChecksum[%s] = %s", algo, sums[algo])
+		}
+	}
+	if md5, _ := MD5(p); md5 != want[HashMD5] {
+		t.Errorf("MD5 = %s", md5)
+	}
+	if _, err := Checksum(p, "sha3"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("unknown algo err = %v", err)
+	}
+
+	RegisterHash("adler32", func() hash.Hash { return adler32.New() })
+	if got, err := Checksum(p, "adler32"); err != nil || got != "062c0215" {
+		t.Errorf("adler32 = %s, %v", got, err)
+	}
+}
+
+func TestChecksumFiles(t *testing.T) {
+	root := t.TempDir()
+	var paths []string
+	for i := 0; i < 20; i++ {
+		p := filepath.Join(root, fmt.Sprintf("f%02d", i))
+		if err := os.WriteFile(p, []byte(strings.Repeat("x", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	paths = append(paths, filepath.Join(root, "missing"))
+	results := ChecksumFiles(context.Background(), paths, HashSHA256, 4)
+	for i, r := range results {
+		if r.Path != paths[i] {
+			t.Fatalf("result %d path = %s", i, r.Path)
+		}
+		if i == len(paths)-1 {
+			if !os.IsNotExist(r.Err) {
+				t.Errorf("missing file err = %v", r.Err)
+			}
+			continue
+		}
+		want, _ := Checksum(paths[i], HashSHA256)
+		if r.Err != nil || r.Sum != want {
+			t.Errorf("%s = %s, %v", r.Path, r.Sum, r.Err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = ChecksumFiles(ctx, paths, HashSHA256, 1)
+	if !errors.Is(results[len(results)-1].Err, context.Canceled) {
+		t.Errorf("canceled err = %v", results[len(results)-1].Err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		"a.txt":       "a",
+		"sub/b.txt":   "b",
+		"sub/c d.txt": "c",
+	})
+	var buf bytes.Buffer
+	if err := GenerateManifest(context.Background(), root, HashSHA256, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[2], "  sub/c d.txt") {
+		t.Fatalf("manifest:\n%s", buf.String())
+	}
+	manifest := buf.String()
+
+	// 与sha256sum -c兼容
+	if sha, err := exec.LookPath("sha256sum"); err == nil {
+		cmd := exec.Command(sha, "-c", "--quiet", "-")
+		cmd.Dir = root
+		cmd.Stdin = strings.NewReader(manifest)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("sha256sum -c: %v\n%s", err, out)
+		}
+	}
+
+	results, err := VerifyManifest(context.Background(), root, HashSHA256, strings.NewReader(manifest), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Status != ManifestOK {
+			t.Errorf("%s: %s", r.Path, r.Status)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "sub", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	results, err = VerifyManifest(context.Background(), root, HashSHA256, strings.NewReader(manifest), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Path+":"+string(r.Status))
+	}
+	if s := strings.Join(got, ","); s != "a.txt:FAILED,sub/b.txt:MISSING,sub/c d.txt:OK" {
+		t.Errorf("verify = %s", s)
+	}
+
+	// 不读取root外部的文件
+	sum := strings.Fields(lines[0])[0]
+	outside := filepath.Join(filepath.Dir(root), "outside.txt")
+	if err := os.WriteFile(outside, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	escape := fmt.Sprintf("%[1]s  ../outside.txt\n%[1]s  %[2]s\n%[1]s  sub/../../outside.txt\n%[1]s  a.txt\n", sum, filepath.ToSlash(outside))
+	results, err = VerifyManifest(context.Background(), root, HashSHA256, strings.NewReader(escape), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results[:3] {
+		if r.Status != ManifestMissing || !errors.Is(r.Err, ErrUnsafePath) {
+			t.Errorf("%s: %s %v", r.Path, r.Status, r.Err)
+		}
+	}
+	if results[3].Status != ManifestMismatch {
+		t.Errorf("a.txt: %s", results[3].Status)
+	}
+
+	_, err = VerifyManifest(context.Background(), root, HashSHA256, strings.NewReader(lines[0]+"\nnot a manifest line\n"), 1)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("malformed manifest err = %v", err)
+	}
+}
+
+func TestManifestEscape(t *testing.T) {
+	line := manifestLine("ab", "x\\y\nz")
+	if line != "\\ab  x\\\\y\\nz\n" {
+		t.Fatalf("line = %q", line)
+	}
+	sum, name, err := parseManifestLine(strings.TrimSuffix(line, "\n"))
+	if err != nil || sum != "ab" || name != "x\\y\nz" {
+		t.Errorf("parse = %q, %q, %v", sum, name, err)
+	}
+	if _, name, _ = parseManifestLine("ab *bin.dat"); name != "bin.dat" {
+		t.Errorf("binary mode name = %q", name)
+	}
+}
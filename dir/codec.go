@@ -0,0 +1,402 @@
+package dir
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-ini/ini"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Codec 结构化文件的编解码
+type Codec interface {
+	Name() string
+	Decode(data []byte, v interface{}) error
+	Encode(v interface{}) ([]byte, error)
+}
+
+// 内置的编解码，按扩展名自动选择，见CodecFor
+var (
+	JSONCodec   Codec = jsonCodec{}
+	YAMLCodec   Codec = yamlCodec{}
+	TOMLCodec   Codec = tomlCodec{}
+	INICodec    Codec = iniCodec{}
+	CSVCodec    Codec = csvCodec{}    // 第一行为表头，见CSVReader
+	NDJSONCodec Codec = ndjsonCodec{} // 每行一个JSON，见NDJSONReader
+	EnvCodec    Codec = envCodec{}    // KEY=VALUE，不展开变量
+)
+
+var ErrUnknownFormat = errors.New("dir: unknown file format")
+
+var (
+	codecMu     sync.RWMutex
+	codecNames  = map[string]Codec{}
+	codecByExts = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec, ".json")
+	RegisterCodec(YAMLCodec, ".yaml", ".yml")
+	RegisterCodec(TOMLCodec, ".toml")
+	RegisterCodec(INICodec, ".ini", ".cfg")
+	RegisterCodec(CSVCodec, ".csv")
+	RegisterCodec(NDJSONCodec, ".ndjson", ".jsonl")
+	RegisterCodec(EnvCodec, ".env")
+}
+
+// RegisterCodec 注册编解码及其对应的扩展名，已存在时覆盖
+func RegisterCodec(c Codec, exts ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecNames[c.Name()] = c
+	for _, ext := range exts {
+		codecByExts[strings.ToLower(ext)] = c
+	}
+}
+
+// CodecByName 按名称查找编解码，如"json"、"toml"
+func CodecByName(name string) (Codec, error) {
+	codecMu.RLock()
+	c, ok := codecNames[name]
+	codecMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+	return c, nil
+}
+
+// CodecFor 按文件扩展名查找编解码，.env.local这类文件使用EnvCodec
+func CodecFor(path string) (Codec, error) {
+	codecMu.RLock()
+	c, ok := codecByExts[strings.ToLower(filepath.Ext(path))]
+	codecMu.RUnlock()
+	if ok {
+		return c, nil
+	}
+	if strings.HasPrefix(filepath.Base(path), ".env.") {
+		return EnvCodec, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+}
+
+// ParseError 解析错误，Line和Column从1开始，未知时为0
+type ParseError struct {
+	Path   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	name := e.Path
+	if name == "" {
+		name = "input"
+	}
+	if e.Line > 0 {
+		name += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			name += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	return fmt.Sprintf("cannot parse %s: %v", name, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// withPath 给解析错误加上文件路径
+func withPath(path string, err error) error {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		e := *pe
+		e.Path = path
+		return &e
+	}
+	return &ParseError{Path: path, Err: err}
+}
+
+// ReadInto 按扩展名解析文件到ptr
+func ReadInto(path string, ptr interface{}) error {
+	return std.ReadInto(path, ptr)
+}
+
+func (h *Helper) ReadInto(path string, ptr interface{}) error {
+	c, err := CodecFor(path)
+	if err != nil {
+		return err
+	}
+	return h.ReadIntoWith(path, c, ptr)
+}
+
+// ReadIntoWith 使用指定的编解码解析文件到ptr
+func ReadIntoWith(path string, c Codec, ptr interface{}) error {
+	return std.ReadIntoWith(path, c, ptr)
+}
+
+func (h *Helper) ReadIntoWith(path string, c Codec, ptr interface{}) error {
+	bs, err := h.ReadBytes(path)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %s", path, err.Error())
+	}
+	if err := c.Decode(bs, ptr); err != nil {
+		return withPath(path, err)
+	}
+	return nil
+}
+
+// WriteFrom 按扩展名编码v并原子地写入文件
+func WriteFrom(path string, v interface{}) error {
+	return std.WriteFrom(path, v)
+}
+
+func (h *Helper) WriteFrom(path string, v interface{}) error {
+	c, err := CodecFor(path)
+	if err != nil {
+		return err
+	}
+	return h.WriteFromWith(path, c, v)
+}
+
+// WriteFromWith 使用指定的编解码编码v并原子地写入文件
+func WriteFromWith(path string, c Codec, v interface{}) error {
+	return std.WriteFromWith(path, c, v)
+}
+
+func (h *Helper) WriteFromWith(path string, c Codec, v interface{}) error {
+	bs, err := c.Encode(v)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s: %s", path, err.Error())
+	}
+	return h.WriteFileAtomic(path, bs, 0666)
+}
+
+// offsetPos 字节偏移转换为行列，offset指向已读取字符之后
+func offsetPos(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = 1 + bytes.Count(before, []byte{'\n'})
+	col = len(before) - bytes.LastIndexByte(before, '\n') - 1
+	if col < 1 {
+		col = 1
+	}
+	return line, col
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Decode(data []byte, v interface{}) error {
+	return jsonError(data, json.Unmarshal(data, v))
+}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bs, '\n'), nil
+}
+
+func jsonError(data []byte, err error) error {
+	var offset int64
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &se):
+		offset = se.Offset
+	case errors.As(err, &te):
+		offset = te.Offset
+	default:
+		return &ParseError{Err: err}
+	}
+	line, col := offsetPos(data, offset)
+	return &ParseError{Line: line, Column: col, Err: err}
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Name() string { return "yaml" }
+
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+func (yamlCodec) Decode(data []byte, v interface{}) error {
+	err := yaml.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+	// yaml.v2只提供行号
+	pe := &ParseError{Err: err}
+	if m := yamlLineRe.FindStringSubmatch(err.Error()); m != nil {
+		pe.Line, _ = strconv.Atoi(m[1])
+	}
+	return pe
+}
+
+func (yamlCodec) Encode(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Name() string { return "toml" }
+
+var tomlPosRe = regexp.MustCompile(`^\((\d+), (\d+)\): `)
+
+func (tomlCodec) Decode(data []byte, v interface{}) error {
+	err := toml.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	m := tomlPosRe.FindStringSubmatch(msg)
+	if m == nil {
+		return &ParseError{Err: err}
+	}
+	line, _ := strconv.Atoi(m[1])
+	col, _ := strconv.Atoi(m[2])
+	return &ParseError{Line: line, Column: col, Err: errors.New(msg[len(m[0]):])}
+}
+
+func (tomlCodec) Encode(v interface{}) ([]byte, error) {
+	return toml.Marshal(v)
+}
+
+// iniCodec 支持结构体(规则同go-ini的MapTo)和map[string]map[string]string，默认分区的名称为"DEFAULT"
+type iniCodec struct{}
+
+func (iniCodec) Name() string { return "ini" }
+
+func (iniCodec) Decode(data []byte, v interface{}) error {
+	f, err := ini.Load(data)
+	if err != nil {
+		// go-ini的错误只包含出错的行内容，据此查找行号
+		return &ParseError{Line: findLine(data, err.Error()), Err: err}
+	}
+	if m, ok := v.(*map[string]map[string]string); ok {
+		if *m == nil {
+			*m = map[string]map[string]string{}
+		}
+		for _, sec := range f.Sections() {
+			if len(sec.Keys()) == 0 && sec.Name() == ini.DefaultSection {
+				continue
+			}
+			(*m)[sec.Name()] = sec.KeysHash()
+		}
+		return nil
+	}
+	if err := f.MapTo(v); err != nil {
+		return &ParseError{Err: err}
+	}
+	return nil
+}
+
+func (iniCodec) Encode(v interface{}) ([]byte, error) {
+	f := ini.Empty()
+	if m, ok := v.(map[string]map[string]string); ok {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sec, err := f.NewSection(name)
+			if err != nil {
+				return nil, err
+			}
+			keys := make([]string, 0, len(m[name]))
+			for k := range m[name] {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if _, err := sec.NewKey(k, m[name][k]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else {
+		// ReflectFrom需要结构体指针
+		if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr {
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			v = p.Interface()
+		}
+		if err := f.ReflectFrom(v); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// findLine 查找msg末尾包含的行内容所在的行号，找不到时返回0
+func findLine(data []byte, msg string) int {
+	msg = strings.TrimSpace(msg)
+	for i, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && strings.HasSuffix(msg, line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// structField 结构体中可以和文本互相转换的字段
+type structField struct {
+	name  string
+	index int
+}
+
+// structFields 导出的非匿名字段，名称取tag，tag为"-"时跳过
+func structFields(t reflect.Type, tag string) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tv, ok := f.Tag.Lookup(tag); ok {
+			if tv = strings.Split(tv, ",")[0]; tv == "-" {
+				continue
+			} else if tv != "" {
+				name = tv
+			}
+		}
+		fields = append(fields, structField{name: name, index: i})
+	}
+	return fields
+}
+
+// fieldByName 先精确匹配，再忽略大小写匹配
+func fieldByName(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
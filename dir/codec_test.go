@@ -0,0 +1,218 @@
+package dir
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecConf struct {
+	Name    string        `json:"name" yaml:"name" toml:"name" ini:"name" env:"NAME"`
+	Port    int           `json:"port" yaml:"port" toml:"port" ini:"port" env:"PORT"`
+	Debug   bool          `json:"debug" yaml:"debug" toml:"debug" ini:"debug" env:"DEBUG"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout" toml:"timeout" ini:"timeout" env:"TIMEOUT"`
+}
+
+func TestReadIntoWriteFrom(t *testing.T) {
+	root := t.TempDir()
+	want := codecConf{Name: "svc one", Port: 8080, Debug: true, Timeout: 3 * time.Second}
+	for _, name := range []string{"c.json", "c.yaml", "c.yml", "c.toml", "c.ini", ".env", "app.env", ".env.local"} {
+		p := filepath.Join(root, name)
+		if err := WriteFrom(p, want); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got codecConf
+		if err := ReadInto(p, &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+
+	if err := ReadInto(filepath.Join(root, "c.xml"), &want); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v", err)
+	}
+	c, err := CodecByName("toml")
+	if err != nil || c != TOMLCodec {
+		t.Fatalf("CodecByName = %v, %v", c, err)
+	}
+	p := filepath.Join(root, "conf.txt")
+	if err := WriteFromWith(p, JSONCodec, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]int
+	if err := ReadIntoWith(p, JSONCodec, &m); err != nil || m["a"] != 1 {
+		t.Fatalf("m = %v, err = %v", m, err)
+	}
+}
+
+func TestReadJsonNoMkdir(t *testing.T) {
+	root := t.TempDir()
+	var v interface{}
+	if err := ReadJson(filepath.Join(root, "missing", "c.json"), &v); err == nil {
+		t.Fatal("expected error")
+	}
+	if IsExist(filepath.Join(root, "missing")) {
+		t.Fatal("ReadJson created the parent directory")
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	root := t.TempDir()
+	cases := []struct {
+		name, content string
+		line, col     int
+	}{
+		{"a.json", "{\n  \"name\": \"x\",\n  \"port\": 1,,\n}", 3, 13},
+		{"b.json", "{\n  \"port\": \"x\"\n}", 2, 13},
+		{"c.yaml", "name: x\nport: [1\n", 2, 0},
+		{"d.toml", "name = \"x\"\nport = = 1\n", 2, 8},
+		{"e.ini", "name = x\n[broken\n", 2, 0},
+		{"f.env", "NAME=x\n\nPORT=\"8080\n", 3, 6},
+		{"g.env", "NAME=x\nPORT=abc\n", 2, 6},
+		{"h.env", "NAME x\n", 1, 1},
+		{"i.ndjson", "{\"name\":\"a\"}\n\n{\"name\":}\n", 3, 9},
+		{"j.csv", "name,port\na,1\nb,x\n", 3, 3},
+		{"k.csv", "name,port\na,1,2\n", 2, 1},
+	}
+	for _, c := range cases {
+		p := filepath.Join(root, c.name)
+		os.WriteFile(p, []byte(c.content), 0644)
+		var err error
+		if strings.HasSuffix(c.name, ".ndjson") || strings.HasSuffix(c.name, ".csv") {
+			var rows []codecConf
+			err = ReadInto(p, &rows)
+		} else {
+			var conf codecConf
+			err = ReadInto(p, &conf)
+		}
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: err = %v, want ParseError", c.name, err)
+			continue
+		}
+		if pe.Path != p || pe.Line != c.line || c.col > 0 && pe.Column != c.col {
+			t.Errorf("%s: got %s:%d:%d, want line %d column %d (%v)", c.name, pe.Path, pe.Line, pe.Column, c.line, c.col, err)
+		}
+	}
+}
+
+func TestEnvCodec(t *testing.T) {
+	data := `# comment
+export A=1
+B = two words # trailing
+C="line\nbreak \"q\""
+D='raw \n'
+E=
+F.G_1=x#notcomment
+`
+	var m map[string]string
+	if err := EnvCodec.Decode([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": "two words", "C": "line\nbreak \"q\"", "D": `raw \n`, "E": "", "F.G_1": "x#notcomment"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("got %q", m)
+	}
+	out, err := EnvCodec.Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]string
+	if err := EnvCodec.Decode(out, &back); err != nil || !reflect.DeepEqual(back, want) {
+		t.Fatalf("round trip %q: %q, %v", out, back, err)
+	}
+}
+
+type csvRow struct {
+	ID    int      `csv:"id"`
+	Name  string   `csv:"name"`
+	Score *float64 `csv:"score"`
+	Skip  string   `csv:"-"`
+}
+
+func TestCSVAndNDJSON(t *testing.T) {
+	root := t.TempDir()
+	score := 1.5
+	rows := []csvRow{{ID: 1, Name: "a, b", Score: &score}, {ID: 2, Name: "c"}}
+	p := filepath.Join(root, "rows.csv")
+	if err := WriteFrom(p, rows); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := ReadString(p); s != "id,name,score\n1,\"a, b\",1.5\n2,c,\n" {
+		t.Fatalf("csv = %q", s)
+	}
+	var got []csvRow
+	if err := ReadInto(p, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "a, b" || *got[0].Score != 1.5 || got[1].Score != nil {
+		t.Fatalf("got %+v", got)
+	}
+	var table [][]string
+	if err := ReadInto(p, &table); err != nil || len(table) != 3 || table[0][2] != "score" {
+		t.Fatalf("table = %q, %v", table, err)
+	}
+
+	// 流式读取，表头忽略大小写
+	os.WriteFile(p, []byte("ID;NAME;extra\n7;x;y\n8;z;w\n"), 0644)
+	r, err := OpenCSV(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Comma = ';'
+	var ids []int
+	for {
+		var row csvRow
+		if err := r.Next(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, row.ID)
+	}
+	if err := r.Close(); err != nil || !reflect.DeepEqual(ids, []int{7, 8}) {
+		t.Fatalf("ids = %v, err = %v", ids, err)
+	}
+
+	p = filepath.Join(root, "rows.jsonl")
+	if err := WriteFrom(p, []map[string]int{{"n": 1}, {"n": 2}, {"n": 3}}); err != nil {
+		t.Fatal(err)
+	}
+	nr, err := OpenNDJSON(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nr.Close()
+	sum := 0
+	for {
+		var rec struct{ N int }
+		if err := nr.Next(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		sum += rec.N
+	}
+	if sum != 6 || nr.Line() != 3 {
+		t.Fatalf("sum = %d, line = %d", sum, nr.Line())
+	}
+}
+
+func TestINIMap(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "c.ini")
+	want := map[string]map[string]string{"db": {"host": "localhost", "port": "3306"}, "log": {"level": "info"}}
+	if err := WriteFrom(p, want); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]map[string]string
+	if err := ReadInto(p, &got); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,659 @@
+package dir
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// setField 把文本转换为字段的值，支持基本类型、time.Duration和encoding.TextUnmarshaler
+func setField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if s == "" {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), s)
+	}
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// formatField setField的逆操作
+func formatField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", v.Type())
+}
+
+// slicePtr 检查v是否为切片指针
+func slicePtr(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("dir: decode target must be a pointer to slice, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+// NDJSONReader 逐行读取NDJSON，不会把整个文件读入内存，空行被跳过
+type NDJSONReader struct {
+	r      *bufio.Reader
+	c      io.Closer
+	path   string
+	line   int
+	closed bool
+}
+
+// NewNDJSONReader 从r读取NDJSON
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{r: bufio.NewReader(r)}
+}
+
+// OpenNDJSON 打开NDJSON文件，用完需要Close
+func OpenNDJSON(path string) (*NDJSONReader, error) {
+	return std.OpenNDJSON(path)
+}
+
+func (h *Helper) OpenNDJSON(path string) (*NDJSONReader, error) {
+	f, err := h.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewNDJSONReader(f)
+	r.c, r.path = f, path
+	return r, nil
+}
+
+// Next 解析下一条记录到v，没有更多记录时返回io.EOF
+func (r *NDJSONReader) Next(v interface{}) error {
+	for {
+		data, err := r.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return err
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		r.line++
+		data = bytes.TrimRight(data, "\r\n")
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := jsonError(data, json.Unmarshal(data, v)); err != nil {
+			pe := err.(*ParseError)
+			pe.Path, pe.Line = r.path, r.line
+			return pe
+		}
+		return nil
+	}
+}
+
+// Line 最后读取的行号
+func (r *NDJSONReader) Line() int {
+	return r.line
+}
+
+// Close 关闭OpenNDJSON打开的文件
+func (r *NDJSONReader) Close() error {
+	if r.c == nil || r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.c.Close()
+}
+
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) Name() string { return "ndjson" }
+
+// Decode v为切片指针，每行追加一个元素
+func (ndjsonCodec) Decode(data []byte, v interface{}) error {
+	s, err := slicePtr(v)
+	if err != nil {
+		return err
+	}
+	r := NewNDJSONReader(bytes.NewReader(data))
+	for {
+		elem := reflect.New(s.Type().Elem())
+		if err := r.Next(elem.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		s.Set(reflect.Append(s, elem.Elem()))
+	}
+}
+
+func (ndjsonCodec) Encode(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("dir: ndjson encode needs a slice, got %T", v)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// CSVReader 逐行读取带表头的CSV，不会把整个文件读入内存
+// 记录可以解析到结构体(按csv tag或字段名匹配表头，忽略大小写)、map[string]string或[]string
+type CSVReader struct {
+	Comma   rune // 分隔符，默认','，需要在第一次读取前设置
+	Comment rune // 注释行的开头字符，默认没有
+
+	src    io.Reader
+	r      *csv.Reader
+	c      io.Closer
+	path   string
+	header []string
+	herr   error
+	fields map[reflect.Type][]int // 结构体类型对应每列的字段下标，-1表示没有对应字段
+	closed bool
+}
+
+// NewCSVReader 从r读取CSV
+func NewCSVReader(r io.Reader) *CSVReader {
+	return &CSVReader{src: r, fields: map[reflect.Type][]int{}}
+}
+
+// OpenCSV 打开CSV文件，用完需要Close
+func OpenCSV(path string) (*CSVReader, error) {
+	return std.OpenCSV(path)
+}
+
+func (h *Helper) OpenCSV(path string) (*CSVReader, error) {
+	f, err := h.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewCSVReader(f)
+	r.c, r.path = f, path
+	return r, nil
+}
+
+func (r *CSVReader) err(err error) error {
+	var ce *csv.ParseError
+	if errors.As(err, &ce) {
+		return &ParseError{Path: r.path, Line: ce.Line, Column: ce.Column, Err: ce.Err}
+	}
+	return err
+}
+
+// Header 表头，文件为空时返回io.EOF
+func (r *CSVReader) Header() ([]string, error) {
+	if r.r != nil {
+		return r.header, r.herr
+	}
+	r.r = csv.NewReader(r.src)
+	if r.Comma != 0 {
+		r.r.Comma = r.Comma
+	}
+	r.r.Comment = r.Comment
+	r.r.ReuseRecord = true
+	header, err := r.r.Read()
+	if err != nil {
+		r.herr = r.err(err)
+		return nil, r.herr
+	}
+	r.header = append([]string(nil), header...)
+	return r.header, nil
+}
+
+// Next 解析下一条记录到v，没有更多记录时返回io.EOF
+func (r *CSVReader) Next(v interface{}) error {
+	if _, err := r.Header(); err != nil {
+		return err
+	}
+	record, err := r.r.Read()
+	if err != nil {
+		return r.err(err)
+	}
+	switch p := v.(type) {
+	case *[]string:
+		*p = append((*p)[:0], record...)
+		return nil
+	case *map[string]string:
+		if *p == nil {
+			*p = make(map[string]string, len(record))
+		}
+		for i, s := range record {
+			if i < len(r.header) {
+				(*p)[r.header[i]] = s
+			}
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dir: csv decode target must be *struct, *map[string]string or *[]string, got %T", v)
+	}
+	sv := rv.Elem()
+	index, ok := r.fields[sv.Type()]
+	if !ok {
+		fields := structFields(sv.Type(), "csv")
+		index = make([]int, len(r.header))
+		for i, name := range r.header {
+			index[i] = -1
+			if f, ok := fieldByName(fields, name); ok {
+				index[i] = f.index
+			}
+		}
+		r.fields[sv.Type()] = index
+	}
+	for i, s := range record {
+		if i >= len(index) || index[i] < 0 {
+			continue
+		}
+		if err := setField(sv.Field(index[i]), s); err != nil {
+			line, col := r.r.FieldPos(i)
+			return &ParseError{Path: r.path, Line: line, Column: col, Err: fmt.Errorf("column %q: %w", r.header[i], err)}
+		}
+	}
+	return nil
+}
+
+// Close 关闭OpenCSV打开的文件
+func (r *CSVReader) Close() error {
+	if r.c == nil || r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.c.Close()
+}
+
+type csvCodec struct{}
+
+func (csvCodec) Name() string { return "csv" }
+
+// Decode v为结构体、map[string]string或[]string的切片指针，[][]string包含表头
+func (csvCodec) Decode(data []byte, v interface{}) error {
+	s, err := slicePtr(v)
+	if err != nil {
+		return err
+	}
+	r := NewCSVReader(bytes.NewReader(data))
+	if rows, ok := v.(*[][]string); ok {
+		header, err := r.Header()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		*rows = append(*rows, append([]string(nil), header...))
+	}
+	for {
+		elem := reflect.New(s.Type().Elem())
+		if err := r.Next(elem.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		s.Set(reflect.Append(s, elem.Elem()))
+	}
+}
+
+// Encode v为结构体、map[string]string或[]string的切片，map的表头按字母排序
+func (csvCodec) Encode(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("dir: csv encode needs a slice, got %T", v)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	var err error
+	switch rows := v.(type) {
+	case [][]string:
+		err = w.WriteAll(rows)
+	case []map[string]string:
+		err = writeCSVMaps(w, rows)
+	default:
+		err = writeCSVStructs(w, rv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func writeCSVMaps(w *csv.Writer, rows []map[string]string) error {
+	seen := map[string]bool{}
+	var header []string
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	sort.Strings(header)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, k := range header {
+			record[i] = row[k]
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVStructs(w *csv.Writer, rv reflect.Value) error {
+	t := rv.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("dir: csv encode needs struct elements, got %s", t)
+	}
+	fields := structFields(t, "csv")
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.name
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		ev := reflect.Indirect(rv.Index(i))
+		if !ev.IsValid() {
+			continue
+		}
+		for j, f := range fields {
+			s, err := formatField(ev.Field(f.index))
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+			record[j] = s
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envCodec 解析KEY=VALUE格式的.env文件
+// 支持#注释、export前缀、单引号(原样)和双引号(支持\n \t \" \\转义)，不展开${VAR}
+// 可以解析到map[string]string或结构体(按env tag或字段名匹配)
+type envCodec struct{}
+
+func (envCodec) Name() string { return "env" }
+
+type envPair struct {
+	key, value string
+	line, col  int // value的位置
+}
+
+func parseEnv(data []byte) ([]envPair, error) {
+	var pairs []envPair
+	for i, raw := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line := strings.TrimRight(raw, "\r")
+		pos := len(line) - len(strings.TrimLeft(line, " \t"))
+		rest := line[pos:]
+		if rest == "" || rest[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(rest, "export ") {
+			pos += len("export ")
+			rest = line[pos:]
+		}
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, &ParseError{Line: lineNo, Column: pos + 1, Err: errors.New("missing '='")}
+		}
+		key := strings.TrimSpace(rest[:eq])
+		if !validEnvKey(key) {
+			return nil, &ParseError{Line: lineNo, Column: pos + 1, Err: fmt.Errorf("invalid key %q", key)}
+		}
+		pos += eq + 1
+		pos += len(line[pos:]) - len(strings.TrimLeft(line[pos:], " \t"))
+		value, err := parseEnvValue(line[pos:])
+		if err != nil {
+			var pe *ParseError
+			errors.As(err, &pe)
+			pe.Line, pe.Column = lineNo, pos+pe.Column
+			return nil, pe
+		}
+		pairs = append(pairs, envPair{key: key, value: value, line: lineNo, col: pos + 1})
+	}
+	return pairs, nil
+}
+
+func validEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseEnvValue 解析等号后的内容，错误的Column相对s从1开始
+func parseEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	var value strings.Builder
+	var end int
+	switch q := s[0]; q {
+	case '\'':
+		i := strings.IndexByte(s[1:], '\'')
+		if i < 0 {
+			return "", &ParseError{Column: 1, Err: errors.New("unterminated single quote")}
+		}
+		value.WriteString(s[1 : i+1])
+		end = i + 2
+	case '"':
+		closed := false
+		for end = 1; end < len(s) && !closed; end++ {
+			c := s[end]
+			switch {
+			case c == '"':
+				closed = true
+			case c == '\\' && end+1 < len(s):
+				end++
+				switch e := s[end]; e {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				case 'r':
+					value.WriteByte('\r')
+				default:
+					value.WriteByte(e)
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		if !closed {
+			return "", &ParseError{Column: 1, Err: errors.New("unterminated double quote")}
+		}
+	default:
+		// 不带引号时空白后的#开始注释
+		v := s
+		for i := 1; i < len(s); i++ {
+			if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+				v = s[:i]
+				break
+			}
+		}
+		return strings.TrimSpace(v), nil
+	}
+	if rest := strings.TrimSpace(s[end:]); rest != "" && rest[0] != '#' {
+		return "", &ParseError{Column: end + 1, Err: fmt.Errorf("unexpected %q after quoted value", rest)}
+	}
+	return value.String(), nil
+}
+
+func (envCodec) Decode(data []byte, v interface{}) error {
+	pairs, err := parseEnv(data)
+	if err != nil {
+		return err
+	}
+	if m, ok := v.(*map[string]string); ok {
+		if *m == nil {
+			*m = make(map[string]string, len(pairs))
+		}
+		for _, p := range pairs {
+			(*m)[p.key] = p.value
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dir: env decode target must be *struct or *map[string]string, got %T", v)
+	}
+	sv := rv.Elem()
+	fields := structFields(sv.Type(), "env")
+	for _, p := range pairs {
+		f, ok := fieldByName(fields, p.key)
+		if !ok {
+			continue
+		}
+		if err := setField(sv.Field(f.index), p.value); err != nil {
+			return &ParseError{Line: p.line, Column: p.col, Err: fmt.Errorf("%s: %w", p.key, err)}
+		}
+	}
+	return nil
+}
+
+// Encode v为map[string]string(按键排序)或结构体，需要时值加双引号
+func (envCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	write := func(k, val string) {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(quoteEnv(val))
+		buf.WriteByte('\n')
+	}
+	if m, ok := v.(map[string]string); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			write(k, m[k])
+		}
+		return buf.Bytes(), nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dir: env encode needs a struct or map[string]string, got %T", v)
+	}
+	for _, f := range structFields(rv.Type(), "env") {
+		s, err := formatField(rv.Field(f.index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		write(f.name, s)
+	}
+	return buf.Bytes(), nil
+}
+
+func quoteEnv(s string) string {
+	if !strings.ContainsAny(s, " \t\r\n\"'#\\") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
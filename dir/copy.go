@@ -0,0 +1,366 @@
+package dir
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Copy 复制文件内容、权限和修改时间，src是符号链接时复制链接指向的文件
+// dst以原子方式替换，复制失败时原dst保持不变
+func Copy(src, dst string) error {
+	return std.Copy(src, dst)
+}
+
+func (h *Helper) Copy(src, dst string) error {
+	in, err := h.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("dir: copy %s: is a directory", src)
+	}
+	w, err := h.NewAtomicWriter(dst, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		w.Abort()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return h.preserve(dst, info)
+}
+
+// preserve 设置权限和修改时间与info一致
+func (h *Helper) preserve(dst string, info os.FileInfo) error {
+	if err := h.fs.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return h.fs.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// CopyDir 递归复制目录，保留权限和修改时间，符号链接按链接复制
+func CopyDir(src, dst string) error {
+	return std.CopyDir(src, dst)
+}
+
+func (h *Helper) CopyDir(src, dst string) error {
+	info, err := h.fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("dir: copy %s: not a directory", src)
+	}
+	_, err = h.SyncDir(src, dst, SyncOptions{})
+	return err
+}
+
+// Move 移动文件或目录，跨设备无法rename时先复制再删除源
+func Move(src, dst string) error {
+	return std.Move(src, dst)
+}
+
+func (h *Helper) Move(src, dst string) error {
+	if err := h.fs.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	err := h.fs.Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+	info, err := h.fs.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		err = h.CopyDir(src, dst)
+	case info.Mode()&os.ModeSymlink != 0:
+		err = h.copySymlink(src, dst)
+	default:
+		err = h.Copy(src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	return h.fs.RemoveAll(src)
+}
+
+func (h *Helper) copySymlink(src, dst string) error {
+	target, err := readlink(h.fs, src)
+	if err != nil {
+		return err
+	}
+	if err = h.fs.RemoveAll(dst); err != nil {
+		return err
+	}
+	return symlink(h.fs, target, dst)
+}
+
+// SyncCompare 判断文件是否需要更新的方式
+type SyncCompare int
+
+const (
+	CompareSizeMtime SyncCompare = iota // 大小和修改时间都相同时跳过
+	CompareChecksum                     // 大小和sha256都相同时跳过
+)
+
+// SyncOptions SyncDir的选项
+type SyncOptions struct {
+	Compare      SyncCompare
+	ModifyWindow time.Duration // 修改时间相差不超过该值时视为相同，用于时间精度较低的文件系统
+	Delete       bool          // 删除dst中src没有的文件和目录
+	DryRun       bool          // 只返回计划执行的操作，不修改dst
+	Exclude      []string      // 不同步的文件和目录，规则同WalkOptions.Exclude，Delete时也不会删除
+}
+
+// SyncOpType 同步操作类型
+type SyncOpType string
+
+const (
+	SyncMkdir   SyncOpType = "mkdir"
+	SyncCopy    SyncOpType = "copy"   // dst不存在
+	SyncUpdate  SyncOpType = "update" // 内容不同
+	SyncChmod   SyncOpType = "chmod"  // 内容相同，权限不同
+	SyncTouch   SyncOpType = "touch"  // 内容相同，修改时间不同(CompareChecksum)
+	SyncSymlink SyncOpType = "symlink"
+	SyncDelete  SyncOpType = "delete"
+)
+
+// SyncOp 一个同步操作，Path为相对路径(使用/分隔)
+type SyncOp struct {
+	Op   SyncOpType
+	Path string
+	Size int64
+}
+
+func (o SyncOp) String() string {
+	return string(o.Op) + " " + o.Path
+}
+
+// SyncDir 把src目录同步到dst：新建缺少的目录和文件，更新变化的文件，保留权限和修改时间
+// 返回执行(DryRun时为计划执行)的操作
+func SyncDir(src, dst string, opts SyncOptions) ([]SyncOp, error) {
+	return std.SyncDir(src, dst, opts)
+}
+
+func (h *Helper) SyncDir(src, dst string, opts SyncOptions) ([]SyncOp, error) {
+	walkOpts := WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude, Exclude: opts.Exclude}
+	srcEntries, err := h.walkAll(src, walkOpts)
+	if err != nil {
+		return nil, err
+	}
+	dstEntries := map[string]WalkEntry{}
+	var dstOrder []WalkEntry
+	if _, err := h.fs.Stat(dst); err == nil {
+		list, err := h.walkAll(dst, walkOpts)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			dstEntries[e.RelPath] = e
+		}
+		dstOrder = list
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var ops []SyncOp
+	srcSet := map[string]bool{}
+	for _, se := range srcEntries {
+		srcSet[se.RelPath] = true
+		de, exists := dstEntries[se.RelPath]
+		kind, dkind := entryKind(se.Info), entryKind(de.Info)
+		if exists && kind != dkind {
+			// 类型不同，先删除
+			ops = append(ops, SyncOp{Op: SyncDelete, Path: se.RelPath})
+			exists = false
+		}
+		switch kind {
+		case os.ModeDir:
+			if !exists {
+				ops = append(ops, SyncOp{Op: SyncMkdir, Path: se.RelPath})
+			} else if se.Info.Mode().Perm() != de.Info.Mode().Perm() {
+				ops = append(ops, SyncOp{Op: SyncChmod, Path: se.RelPath})
+			}
+		case os.ModeSymlink:
+			if exists && h.sameLink(se.Path, de.Path) {
+				continue
+			}
+			if exists {
+				ops = append(ops, SyncOp{Op: SyncDelete, Path: se.RelPath})
+			}
+			ops = append(ops, SyncOp{Op: SyncSymlink, Path: se.RelPath})
+		default:
+			if !exists {
+				ops = append(ops, SyncOp{Op: SyncCopy, Path: se.RelPath, Size: se.Info.Size()})
+				continue
+			}
+			op, err := h.compareFiles(se, de, opts)
+			if err != nil {
+				return ops, err
+			}
+			if op != "" {
+				ops = append(ops, SyncOp{Op: op, Path: se.RelPath, Size: se.Info.Size()})
+			}
+		}
+	}
+	if opts.Delete {
+		// 目录已删除时跳过其中的文件
+		var removed string
+		for _, de := range dstOrder {
+			if removed != "" && strings.HasPrefix(de.RelPath, removed+"/") {
+				continue
+			}
+			if srcSet[de.RelPath] {
+				continue
+			}
+			ops = append(ops, SyncOp{Op: SyncDelete, Path: de.RelPath})
+			if de.Info.IsDir() {
+				removed = de.RelPath
+			}
+		}
+	}
+	if opts.DryRun {
+		return ops, nil
+	}
+	if err := h.fs.MkdirAll(dst, os.ModePerm); err != nil {
+		return nil, err
+	}
+	// 已存在的只读目录先加上写权限，最后再恢复
+	for _, se := range srcEntries {
+		de, exists := dstEntries[se.RelPath]
+		if se.Info.IsDir() && exists && de.Info.IsDir() && de.Info.Mode().Perm()&0700 != 0700 {
+			if err := h.fs.Chmod(de.Path, de.Info.Mode().Perm()|0700); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, op := range ops {
+		if err := h.applySyncOp(src, dst, op); err != nil {
+			return ops[:i], fmt.Errorf("dir: sync %s: %w", op, err)
+		}
+	}
+	// 目录的权限和修改时间在其中的文件写完后再设置，从最深的目录开始，只读目录中也能写入文件
+	for i := len(srcEntries) - 1; i >= 0; i-- {
+		se := srcEntries[i]
+		if se.Info.IsDir() {
+			p := filepath.Join(dst, filepath.FromSlash(se.RelPath))
+			if err := h.fs.Chmod(p, se.Info.Mode().Perm()); err != nil {
+				return ops, err
+			}
+			_ = h.fs.Chtimes(p, se.Info.ModTime(), se.Info.ModTime())
+		}
+	}
+	if info, err := h.fs.Stat(src); err == nil {
+		_ = h.fs.Chmod(dst, info.Mode().Perm())
+		_ = h.fs.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return ops, nil
+}
+
+func (h *Helper) walkAll(root string, opts WalkOptions) ([]WalkEntry, error) {
+	var list []WalkEntry
+	err := h.Walk(root, opts, func(e WalkEntry) error {
+		list = append(list, e)
+		return nil
+	})
+	return list, err
+}
+
+// entryKind 目录、符号链接或普通文件(0)
+func entryKind(info os.FileInfo) os.FileMode {
+	if info == nil {
+		return 0
+	}
+	return info.Mode() & (os.ModeDir | os.ModeSymlink)
+}
+
+func (h *Helper) sameLink(a, b string) bool {
+	ta, err1 := readlink(h.fs, a)
+	tb, err2 := readlink(h.fs, b)
+	return err1 == nil && err2 == nil && ta == tb
+}
+
+func (h *Helper) compareFiles(se, de WalkEntry, opts SyncOptions) (SyncOpType, error) {
+	if se.Info.Size() != de.Info.Size() {
+		return SyncUpdate, nil
+	}
+	diff := se.Info.ModTime().Sub(de.Info.ModTime())
+	if diff < 0 {
+		diff = -diff
+	}
+	sameTime := diff <= opts.ModifyWindow
+	if opts.Compare == CompareChecksum {
+		same, err := h.sameContent(se.Path, de.Path)
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			return SyncUpdate, nil
+		}
+	} else if !sameTime {
+		return SyncUpdate, nil
+	}
+	if se.Info.Mode().Perm() != de.Info.Mode().Perm() {
+		return SyncChmod, nil
+	}
+	if !sameTime {
+		return SyncTouch, nil
+	}
+	return "", nil
+}
+
+func (h *Helper) sameContent(a, b string) (bool, error) {
+	ha, err := h.Checksum(a, HashSHA256)
+	if err != nil {
+		return false, err
+	}
+	hb, err := h.Checksum(b, HashSHA256)
+	if err != nil {
+		return false, err
+	}
+	return ha == hb, nil
+}
+
+func (h *Helper) applySyncOp(src, dst string, op SyncOp) error {
+	sp := filepath.Join(src, filepath.FromSlash(op.Path))
+	dp := filepath.Join(dst, filepath.FromSlash(op.Path))
+	switch op.Op {
+	case SyncDelete:
+		return h.fs.RemoveAll(dp)
+	case SyncMkdir:
+		// 权限在SyncDir最后设置
+		if err := h.fs.Mkdir(dp, 0700); err != nil && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	case SyncCopy, SyncUpdate:
+		return h.Copy(sp, dp)
+	case SyncChmod, SyncTouch:
+		info, err := h.fs.Stat(sp)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// 权限在SyncDir最后设置
+			return nil
+		}
+		return h.preserve(dp, info)
+	case SyncSymlink:
+		return h.copySymlink(sp, dp)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func opsString(ops []SyncOp) string {
+	s := make([]string, len(ops))
+	for i, op := range ops {
+		s[i] = op.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func readText(t *testing.T, p string) string {
+	t.Helper()
+	s, err := ReadString(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCopy(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "a.txt")
+	dst := filepath.Join(root, "sub", "b.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got := readText(t, dst); got != "hello" {
+		t.Fatalf("content = %q", got)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v", info.ModTime())
+	}
+	if err := Copy(root, filepath.Join(root, "x")); err == nil {
+		t.Error("copy a directory should fail")
+	}
+}
+
+func TestCopyDirAndMove(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	makeTree(t, src, map[string]string{
+		"a.txt":      "a",
+		"sub/b.txt":  "b",
+		"sub/deep/c": "c",
+		"empty/":     "",
+		"sub/run.sh": "#!/bin/sh",
+	})
+	if err := os.Chmod(filepath.Join(src, "sub", "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(root, "dst")
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	want := walkRel(t, src, WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude})
+	if got := walkRel(t, dst, WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude}); got != want {
+		t.Fatalf("copied tree = %s, want %s", got, want)
+	}
+	if info, _ := os.Stat(filepath.Join(dst, "sub", "run.sh")); info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v", info.Mode().Perm())
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "a.txt" {
+		t.Errorf("link = %q, %v", target, err)
+	}
+
+	moved := filepath.Join(root, "moved", "tree")
+	if err := Move(dst, moved); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(dst) {
+		t.Error("source still exists after move")
+	}
+	if got := readText(t, filepath.Join(moved, "sub", "deep", "c")); got != "c" {
+		t.Errorf("moved content = %q", got)
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	makeTree(t, src, map[string]string{
+		"keep.txt":    "same",
+		"changed.txt": "new content",
+		"new/n.txt":   "n",
+		"skip.log":    "log",
+	})
+	if _, err := SyncDir(src, dst, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 再次同步没有操作
+	ops, err := SyncDir(src, dst, SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 0 {
+		t.Fatalf("second sync ops = %s", opsString(ops))
+	}
+
+	if err := os.WriteFile(filepath.Join(src, "changed.txt"), []byte("changed again"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "keep.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	makeTree(t, dst, map[string]string{
+		"extra/e.txt": "e",
+		"extra.txt":   "x",
+	})
+	if err := os.Remove(filepath.Join(src, "skip.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "skip.log"), []byte("dst only"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := SyncOptions{Delete: true, DryRun: true, Exclude: []string{"*.log"}}
+	ops, err = SyncDir(src, dst, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "update changed.txt,chmod keep.txt,delete extra,delete extra.txt"
+	if got := opsString(ops); got != want {
+		t.Fatalf("dry run ops = %s, want %s", got, want)
+	}
+	if !IsExist(filepath.Join(dst, "extra.txt")) {
+		t.Fatal("dry run modified dst")
+	}
+
+	opts.DryRun = false
+	if ops, err = SyncDir(src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := opsString(ops); got != want {
+		t.Fatalf("ops = %s, want %s", got, want)
+	}
+	if got := readText(t, filepath.Join(dst, "changed.txt")); got != "changed again" {
+		t.Errorf("changed.txt = %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dst, "keep.txt")); info.Mode().Perm() != 0600 {
+		t.Errorf("keep.txt mode = %v", info.Mode().Perm())
+	}
+	if IsExist(filepath.Join(dst, "extra")) || IsExist(filepath.Join(dst, "extra.txt")) {
+		t.Error("extra files not deleted")
+	}
+	if !IsExist(filepath.Join(dst, "skip.log")) {
+		t.Error("excluded file deleted")
+	}
+}
+
+func TestSyncDirReadOnlyDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory permissions")
+	}
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	makeTree(t, src, map[string]string{"ro/sub/a.txt": "a", "ro/b.txt": "b"})
+	ro, sub := filepath.Join(src, "ro"), filepath.Join(src, "ro", "sub")
+	t.Cleanup(func() {
+		for _, d := range []string{ro, sub, filepath.Join(dst, "ro"), filepath.Join(dst, "ro", "sub")} {
+			_ = os.Chmod(d, 0755)
+		}
+	})
+	setPerm := func(perm os.FileMode) {
+		for _, d := range []string{sub, ro} {
+			if err := os.Chmod(d, perm); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	setPerm(0555)
+	if _, err := SyncDir(src, dst, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"ro", "ro/sub"} {
+		if info, _ := os.Stat(filepath.Join(dst, d)); info.Mode().Perm() != 0555 {
+			t.Errorf("%s mode = %v", d, info.Mode().Perm())
+		}
+	}
+	if got := readText(t, filepath.Join(dst, "ro", "sub", "a.txt")); got != "a" {
+		t.Errorf("a.txt = %q", got)
+	}
+
+	// 再次同步时写入已存在的只读目录
+	setPerm(0755)
+	if err := os.WriteFile(filepath.Join(sub, "a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setPerm(0555)
+	if _, err := SyncDir(src, dst, SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readText(t, filepath.Join(dst, "ro", "sub", "a.txt")); got != "changed" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dst, "ro", "sub")); info.Mode().Perm() != 0555 {
+		t.Errorf("ro/sub mode = %v", info.Mode().Perm())
+	}
+}
+
+func TestSyncDirChecksum(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	makeTree(t, src, map[string]string{"a": "aaaa", "b": "bbbb"})
+	makeTree(t, dst, map[string]string{"a": "aaaa", "b": "xxxx"})
+	mtime := time.Now().Add(-time.Hour)
+	times := map[string]time.Time{
+		filepath.Join(src, "a"): mtime,
+		filepath.Join(dst, "a"): mtime.Add(-time.Minute),
+		filepath.Join(src, "b"): mtime,
+		filepath.Join(dst, "b"): mtime,
+	}
+	for p, tm := range times {
+		if err := os.Chtimes(p, tm, tm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 大小和修改时间相同，只有校验和能发现b不同
+	ops, err := SyncDir(src, dst, SyncOptions{Compare: CompareChecksum, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opsString(ops); got != "touch a,update b" {
+		t.Fatalf("ops = %s", got)
+	}
+	ops, err = SyncDir(src, dst, SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opsString(ops); got != "update a" {
+		t.Fatalf("size/mtime ops = %s", got)
+	}
+}
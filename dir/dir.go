@@ -1,21 +1,15 @@
 package dir
 
 import (
-	"bufio"
 	"crypto/md5"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
-
-	"gopkg.in/yaml.v2"
 )
 
 // GetCurrentAbPath 项目根目录绝对路径
@@ -88,23 +82,35 @@ func Dir(fp string) string {
 
 //MkDir 新建不存在的文件夹
 func MkDir(fp string) error {
-	if IsExist(fp) {
+	return std.MkDir(fp)
+}
+
+func (h *Helper) MkDir(fp string) error {
+	if h.IsExist(fp) {
 		return nil
 	}
-	return os.MkdirAll(fp, os.ModePerm)
+	return h.fs.MkdirAll(fp, os.ModePerm)
 }
 
 // IsExist 检测文件或者目录是否存在
 // 不存在的时候将返回 fasle
 func IsExist(fp string) bool {
-	_, err := os.Stat(fp)
+	return std.IsExist(fp)
+}
+
+func (h *Helper) IsExist(fp string) bool {
+	_, err := h.fs.Stat(fp)
 	return err == nil || os.IsExist(err)
 }
 
 // IsFile checks whether the path is a file,
 // it returns false when it's a directory or does not exist.
 func IsFile(fp string) bool {
-	f, e := os.Stat(fp)
+	return std.IsFile(fp)
+}
+
+func (h *Helper) IsFile(fp string) bool {
+	f, e := h.fs.Stat(fp)
 	if e != nil {
 		return false
 	}
@@ -112,26 +118,38 @@ func IsFile(fp string) bool {
 }
 
 func Remove(filename string) error {
-	if IsFile(filename) && IsExist(filename) {
-		return os.Remove(filename)
+	return std.Remove(filename)
+}
+
+func (h *Helper) Remove(filename string) error {
+	if h.IsFile(filename) && h.IsExist(filename) {
+		return h.fs.Remove(filename)
 	}
 	return nil
 }
 
 func ReadBytes(cpath string) ([]byte, error) {
-	if !IsExist(cpath) {
+	return std.ReadBytes(cpath)
+}
+
+func (h *Helper) ReadBytes(cpath string) ([]byte, error) {
+	if !h.IsExist(cpath) {
 		return nil, fmt.Errorf("%s not exists", cpath)
 	}
 
-	if !IsFile(cpath) {
+	if !h.IsFile(cpath) {
 		return nil, fmt.Errorf("%s not file", cpath)
 	}
 
-	return ioutil.ReadFile(cpath)
+	return h.fs.ReadFile(cpath)
 }
 
 func ReadString(cpath string) (string, error) {
-	bs, err := ReadBytes(cpath)
+	return std.ReadString(cpath)
+}
+
+func (h *Helper) ReadString(cpath string) (string, error) {
+	bs, err := h.ReadBytes(cpath)
 	if err != nil {
 		return "", err
 	}
@@ -140,7 +158,11 @@ func ReadString(cpath string) (string, error) {
 }
 
 func ReadStringTrim(cpath string) (string, error) {
-	out, err := ReadString(cpath)
+	return std.ReadStringTrim(cpath)
+}
+
+func (h *Helper) ReadStringTrim(cpath string) (string, error) {
+	out, err := h.ReadString(cpath)
 	if err != nil {
 		return "", err
 	}
@@ -149,63 +171,47 @@ func ReadStringTrim(cpath string) (string, error) {
 }
 
 func ReadYaml(cpath string, cptr interface{}) error {
-	bs, err := ReadBytes(cpath)
-	if err != nil {
-		return fmt.Errorf("cannot read %s: %s", cpath, err.Error())
-	}
-
-	err = yaml.Unmarshal(bs, cptr)
-	if err != nil {
-		return fmt.Errorf("cannot parse %s: %s", cpath, err.Error())
-	}
+	return std.ReadYaml(cpath, cptr)
+}
 
-	return nil
+func (h *Helper) ReadYaml(cpath string, cptr interface{}) error {
+	return h.ReadIntoWith(cpath, YAMLCodec, cptr)
 }
 
 func ReadJson(cpath string, cptr interface{}) error {
-	os.MkdirAll(path.Dir(cpath), os.ModePerm)
-	bs, err := ReadBytes(cpath)
-	if err != nil {
-		return fmt.Errorf("cannot read %s: %s", cpath, err.Error())
-	}
-
-	err = json.Unmarshal(bs, cptr)
-	if err != nil {
-		return fmt.Errorf("cannot parse %s: %s", cpath, err.Error())
-	}
+	return std.ReadJson(cpath, cptr)
+}
 
-	return nil
+func (h *Helper) ReadJson(cpath string, cptr interface{}) error {
+	return h.ReadIntoWith(cpath, JSONCodec, cptr)
 }
 
+// WriteBytes 原子地写入文件，写入失败时原文件保持不变，见WriteFileAtomic
 func WriteBytes(filePath string, b []byte) (int, error) {
-	os.MkdirAll(path.Dir(filePath), os.ModePerm)
-	fw, err := os.Create(filePath)
-	if err != nil {
+	return std.WriteBytes(filePath, b)
+}
+
+func (h *Helper) WriteBytes(filePath string, b []byte) (int, error) {
+	if err := h.WriteFileAtomic(filePath, b, 0666); err != nil {
 		return 0, err
 	}
-	defer fw.Close()
-	return fw.Write(b)
+	return len(b), nil
 }
 
 func WriteString(filePath string, s string) (int, error) {
-	return WriteBytes(filePath, []byte(s))
+	return std.WriteString(filePath, s)
 }
 
-func MD5(file string) (string, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
+func (h *Helper) WriteString(filePath string, s string) (int, error) {
+	return h.WriteBytes(filePath, []byte(s))
+}
 
-	r := bufio.NewReader(f)
-	h := md5.New()
+func MD5(file string) (string, error) {
+	return std.MD5(file)
+}
 
-	_, err = io.Copy(h, r)
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+func (h *Helper) MD5(file string) (string, error) {
+	return h.Checksum(file, HashMD5)
 }
 
 func Md5Byte(p []byte) (string, error) {
@@ -224,24 +230,32 @@ func OpenLogFile(fp string) (*os.File, error) {
 
 // 添加文本
 func AppendFile(filePath string, b []byte) error {
-	os.MkdirAll(path.Dir(filePath), os.ModePerm)
-	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_RDWR|os.O_CREATE, os.ModePerm)
+	return std.AppendFile(filePath, b)
+}
+
+func (h *Helper) AppendFile(filePath string, b []byte) error {
+	h.fs.MkdirAll(path.Dir(filePath), os.ModePerm)
+	f, err := h.fs.OpenFile(filePath, os.O_APPEND|os.O_RDWR|os.O_CREATE, os.ModePerm)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	f.WriteString(string(b) + "\r\n\r\n")
+	f.Write([]byte(string(b) + "\r\n\r\n"))
 
 	return nil
 }
 
 // list dirs under dirPath
 func DirsUnder(dirPath string) ([]string, error) {
-	if !IsExist(dirPath) {
+	return std.DirsUnder(dirPath)
+}
+
+func (h *Helper) DirsUnder(dirPath string) ([]string, error) {
+	if !h.IsExist(dirPath) {
 		return []string{}, nil
 	}
 
-	fs, err := ioutil.ReadDir(dirPath)
+	fs, err := h.fs.ReadDir(dirPath)
 	if err != nil {
 		return []string{}, err
 	}
@@ -266,11 +280,15 @@ func DirsUnder(dirPath string) ([]string, error) {
 
 // list files under dirPath
 func FilesUnder(dirPath string) ([]string, error) {
-	if !IsExist(dirPath) {
+	return std.FilesUnder(dirPath)
+}
+
+func (h *Helper) FilesUnder(dirPath string) ([]string, error) {
+	if !h.IsExist(dirPath) {
 		return []string{}, nil
 	}
 
-	fs, err := ioutil.ReadDir(dirPath)
+	fs, err := h.fs.ReadDir(dirPath)
 	if err != nil {
 		return []string{}, err
 	}
@@ -0,0 +1,138 @@
+package dir
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrReadOnly    = errors.New("dir: read-only file system")
+	ErrUnsupported = errors.New("dir: operation not supported")
+)
+
+// File 可读写的文件，*os.File实现了该接口
+type File interface {
+	fs.ReadDirFile
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	Name() string
+	Sync() error
+	Truncate(size int64) error
+}
+
+// FS 文件系统，读操作兼容io/fs，另外提供写操作
+// 与io/fs不同，路径可以是绝对路径，由具体实现决定如何解释
+type FS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+	Lstat(name string) (fs.FileInfo, error)
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// SymlinkFS 支持符号链接的文件系统
+type SymlinkFS interface {
+	FS
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	EvalSymlinks(name string) (string, error)
+}
+
+// OsFS 操作系统的文件系统，路径直接交给os包
+type OsFS struct{}
+
+// OS 包级函数使用的文件系统
+var OS FS = OsFS{}
+
+func (OsFS) Open(name string) (fs.File, error)            { return os.Open(name) }
+func (OsFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (OsFS) Lstat(name string) (fs.FileInfo, error)       { return os.Lstat(name) }
+func (OsFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (OsFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (OsFS) Mkdir(name string, perm fs.FileMode) error    { return os.Mkdir(name, perm) }
+func (OsFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
+func (OsFS) Remove(name string) error                     { return os.Remove(name) }
+func (OsFS) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (OsFS) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (OsFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (OsFS) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (OsFS) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (OsFS) EvalSymlinks(name string) (string, error)     { return filepath.EvalSymlinks(name) }
+
+func (OsFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// 避免返回包含nil指针的接口
+		return nil, err
+	}
+	return f, nil
+}
+
+func (OsFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OsFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func isOS(fsys FS) bool {
+	_, ok := fsys.(OsFS)
+	return ok
+}
+
+// readlink 文件系统不支持符号链接时返回ErrUnsupported
+func readlink(fsys FS, name string) (string, error) {
+	if s, ok := fsys.(SymlinkFS); ok {
+		return s.Readlink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: ErrUnsupported}
+}
+
+func symlink(fsys FS, oldname, newname string) error {
+	if s, ok := fsys.(SymlinkFS); ok {
+		return s.Symlink(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrUnsupported}
+}
+
+// evalSymlinks 文件系统不支持符号链接时只检查路径是否存在
+func evalSymlinks(fsys FS, name string) (string, error) {
+	if s, ok := fsys.(SymlinkFS); ok {
+		return s.EvalSymlinks(name)
+	}
+	if _, err := fsys.Stat(name); err != nil {
+		return "", err
+	}
+	return filepath.Clean(name), nil
+}
+
+// Helper 在指定的文件系统上使用本包的函数，包级函数等同于NewHelper(OS)的方法
+type Helper struct {
+	fs FS
+}
+
+// NewHelper 使用fsys的Helper
+func NewHelper(fsys FS) *Helper {
+	return &Helper{fs: fsys}
+}
+
+var std = NewHelper(OS)
+
+// FS 返回使用的文件系统
+func (h *Helper) FS() FS {
+	return h.fs
+}
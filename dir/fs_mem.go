@@ -0,0 +1,513 @@
+package dir
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("not a directory")
+	errNotEmpty = errors.New("directory not empty")
+	errBadFD    = errors.New("bad file descriptor")
+)
+
+// MemFS 内存中的文件系统，用于测试，不支持符号链接
+// 路径按/分隔处理，"/a/b"、"a/b"和"./a/b"是同一个文件
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode // key见memKey，根目录为"."
+}
+
+type memNode struct {
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFS 创建只有根目录的内存文件系统
+func NewMemFS() *MemFS {
+	return &MemFS{nodes: map[string]*memNode{
+		".": {mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+func memKey(name string) string {
+	p := path.Clean("/" + filepath.ToSlash(name))
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// isUnder key是否在dir下(不含dir本身)
+func isUnder(key, dir string) bool {
+	if dir == "." {
+		return key != "."
+	}
+	return strings.HasPrefix(key, dir+"/")
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.modTime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() interface{}   { return nil }
+
+func (n *memNode) info(key string) *memInfo {
+	return &memInfo{name: path.Base(key), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// checkParent 调用时需持有锁
+func (m *MemFS) checkParent(key string) error {
+	p, ok := m.nodes[path.Dir(key)]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	if !p.mode.IsDir() {
+		return errNotDir
+	}
+	return nil
+}
+
+// children 调用时需持有锁，按名称排序
+func (m *MemFS) children(key string) []fs.DirEntry {
+	var entries []fs.DirEntry
+	for k, n := range m.nodes {
+		if k != "." && path.Dir(k) == key {
+			entries = append(entries, fs.FileInfoToDirEntry(n.info(k)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+func (m *MemFS) hasChildren(key string) bool {
+	for k := range m.nodes {
+		if k != "." && path.Dir(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	f, err := m.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	n, ok := m.nodes[key]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !ok:
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err := m.checkParent(key); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		n = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[key] = n
+	case n.mode.IsDir() && writable:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	case writable && flag&os.O_TRUNC != 0:
+		n.data, n.modTime = nil, time.Now()
+	}
+	return &memFile{fs: m, name: name, key: key, node: n, flag: flag}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	key := memKey(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return n.info(key), nil
+}
+
+// Lstat 不支持符号链接，与Stat相同
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	key := memKey(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return m.children(key), nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	key := memKey(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.nodes[key]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.checkParent(key); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	m.nodes[key] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key == "." {
+		return nil
+	}
+	p := ""
+	for _, seg := range strings.Split(key, "/") {
+		if p == "" {
+			p = seg
+		} else {
+			p += "/" + seg
+		}
+		if n, ok := m.nodes[p]; ok {
+			if !n.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+			}
+			continue
+		}
+		m.nodes[p] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[key]
+	switch {
+	case key == ".":
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	case !ok:
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	case n.mode.IsDir() && m.hasChildren(key):
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, key)
+	return nil
+}
+
+// RemoveAll 删除根目录时保留根目录本身
+func (m *MemFS) RemoveAll(name string) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.nodes {
+		if isUnder(k, key) || (k == key && k != ".") {
+			delete(m.nodes, k)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	oldKey, newKey := memKey(oldname), memKey(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	n, ok := m.nodes[oldKey]
+	if !ok {
+		return linkErr(fs.ErrNotExist)
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if oldKey == "." || isUnder(newKey, oldKey) {
+		return linkErr(fs.ErrInvalid)
+	}
+	if err := m.checkParent(newKey); err != nil {
+		return linkErr(err)
+	}
+	if dst, ok := m.nodes[newKey]; ok {
+		switch {
+		case dst.mode.IsDir() && !n.mode.IsDir():
+			return linkErr(errIsDir)
+		case !dst.mode.IsDir() && n.mode.IsDir():
+			return linkErr(errNotDir)
+		case dst.mode.IsDir() && m.hasChildren(newKey):
+			return linkErr(errNotEmpty)
+		}
+	}
+	moved := map[string]*memNode{newKey: n}
+	for k, c := range m.nodes {
+		if isUnder(k, oldKey) {
+			moved[newKey+k[len(oldKey):]] = c
+			delete(m.nodes, k)
+		}
+	}
+	delete(m.nodes, oldKey)
+	for k, c := range moved {
+		m.nodes[k] = c
+	}
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[key]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	n.mode = n.mode&fs.ModeType | mode.Perm()
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	key := memKey(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[key]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	n.modTime = mtime
+	return nil
+}
+
+// memFile 打开的文件，文件被删除后仍可读写，与unix行为一致
+type memFile struct {
+	fs     *MemFS
+	name   string
+	key    string
+	node   *memNode
+	flag   int
+	offset int64
+	dirPos int
+	closed bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) pathErr(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, f.pathErr("stat", fs.ErrClosed)
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(f.key), nil
+}
+
+func (f *memFile) checkRead(op string) error {
+	switch {
+	case f.closed:
+		return f.pathErr(op, fs.ErrClosed)
+	case f.flag&os.O_WRONLY != 0:
+		return f.pathErr(op, errBadFD)
+	case f.node.mode.IsDir():
+		return f.pathErr(op, errIsDir)
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if err := f.checkRead("read"); err != nil {
+		return 0, err
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.checkRead("read"); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, f.pathErr("readat", fs.ErrInvalid)
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	switch {
+	case f.closed:
+		return 0, f.pathErr("write", fs.ErrClosed)
+	case f.flag&(os.O_WRONLY|os.O_RDWR) == 0:
+		return 0, f.pathErr("write", errBadFD)
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	n := f.node
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(n.data))
+	}
+	if end := f.offset + int64(len(p)); end > int64(len(n.data)) {
+		n.data = append(n.data, make([]byte, end-int64(len(n.data)))...)
+	}
+	copy(n.data[f.offset:], p)
+	f.offset += int64(len(p))
+	n.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, f.pathErr("seek", fs.ErrClosed)
+	}
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	}
+	if offset < 0 {
+		return 0, f.pathErr("seek", fs.ErrInvalid)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.closed {
+		return nil, f.pathErr("readdir", fs.ErrClosed)
+	}
+	if !f.node.mode.IsDir() {
+		return nil, f.pathErr("readdir", errNotDir)
+	}
+	f.fs.mu.RLock()
+	entries := f.fs.children(f.key)
+	f.fs.mu.RUnlock()
+	if f.dirPos > len(entries) {
+		f.dirPos = len(entries)
+	}
+	entries = entries[f.dirPos:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	f.dirPos += len(entries)
+	return entries, nil
+}
+
+func (f *memFile) Sync() error {
+	if f.closed {
+		return f.pathErr("sync", fs.ErrClosed)
+	}
+	return nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	switch {
+	case f.closed:
+		return f.pathErr("truncate", fs.ErrClosed)
+	case f.flag&(os.O_WRONLY|os.O_RDWR) == 0:
+		return f.pathErr("truncate", errBadFD)
+	case size < 0:
+		return f.pathErr("truncate", fs.ErrInvalid)
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	n := f.node
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size:size]
+	} else {
+		n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+	}
+	n.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return f.pathErr("close", fs.ErrClosed)
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,497 @@
+package dir
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// overlayFS 写时复制：读取时上层优先，写入前先把下层的文件复制到上层，下层保持不变
+// 删除下层的文件时只在内存中记录，不修改下层
+type overlayFS struct {
+	base, layer FS
+
+	mu      sync.Mutex
+	deleted map[string]bool // 已删除的下层路径
+	opaque  map[string]bool // 删除后重新创建的目录，不再显示下层中的内容
+}
+
+// NewOverlayFS base为只读的下层，所有修改写入layer
+func NewOverlayFS(base, layer FS) FS {
+	return &overlayFS{base: base, layer: layer, deleted: map[string]bool{}, opaque: map[string]bool{}}
+}
+
+func overlayKey(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// hidden 下层的name是否已被删除或被重新创建的目录遮住，调用时需持有锁
+func (o *overlayFS) hidden(name string) bool {
+	p := overlayKey(name)
+	for {
+		if o.deleted[p] {
+			return true
+		}
+		parent := path.Dir(p)
+		if parent == p {
+			return false
+		}
+		if o.opaque[parent] {
+			return true
+		}
+		p = parent
+	}
+}
+
+// stat 返回合并后的文件信息以及是否在上层，调用时需持有锁
+func (o *overlayFS) stat(name string, lstat bool) (fs.FileInfo, bool, error) {
+	layerStat, baseStat := o.layer.Stat, o.base.Stat
+	if lstat {
+		layerStat, baseStat = o.layer.Lstat, o.base.Lstat
+	}
+	if info, err := layerStat(name); err == nil {
+		return info, true, nil
+	}
+	if o.hidden(name) {
+		return nil, false, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	info, err := baseStat(name)
+	return info, false, err
+}
+
+// baseVisible 下层是否存在且未被删除，调用时需持有锁
+func (o *overlayFS) baseVisible(name string) bool {
+	if o.hidden(name) {
+		return false
+	}
+	_, err := o.base.Lstat(name)
+	return err == nil
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, _, err := o.stat(name, false)
+	return info, err
+}
+
+func (o *overlayFS) Lstat(name string) (fs.FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, _, err := o.stat(name, true)
+	return info, err
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (o *overlayFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, inLayer, err := o.stat(name, false)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		switch {
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			entries, err := o.readDir(name)
+			if err != nil {
+				return nil, err
+			}
+			return &overlayDir{name: name, info: info, entries: entries}, nil
+		case inLayer:
+			return o.layer.OpenFile(name, flag, perm)
+		}
+		return o.base.OpenFile(name, flag, perm)
+	}
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err == nil && info.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	case err == nil && !inLayer:
+		if err := o.copyUp(name, info, flag&os.O_TRUNC == 0); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		if err := o.ensureParent(name); err != nil {
+			return nil, err
+		}
+		delete(o.deleted, overlayKey(name))
+	}
+	return o.layer.OpenFile(name, flag, perm)
+}
+
+// ensureParent 在上层创建name的父目录，权限和时间与下层一致，调用时需持有锁
+func (o *overlayFS) ensureParent(name string) error {
+	parent := filepath.Dir(name)
+	if parent == name {
+		return nil
+	}
+	info, inLayer, err := o.stat(parent, false)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "open", Path: parent, Err: errNotDir}
+	}
+	if inLayer {
+		return nil
+	}
+	if err := o.ensureParent(parent); err != nil {
+		return err
+	}
+	if err := o.layer.Mkdir(parent, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return o.layer.Chtimes(parent, info.ModTime(), info.ModTime())
+}
+
+// copyUp 把下层的name复制到上层，withData为false时只创建空文件，调用时需持有锁
+func (o *overlayFS) copyUp(name string, info fs.FileInfo, withData bool) error {
+	if err := o.ensureParent(name); err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		if err := o.layer.Mkdir(name, info.Mode().Perm()); err != nil {
+			return err
+		}
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := readlink(o.base, name)
+		if err != nil {
+			return err
+		}
+		return symlink(o.layer, target, name)
+	default:
+		dst, err := o.layer.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if withData {
+			err = copyFrom(o.base, name, dst)
+		}
+		if err1 := dst.Close(); err == nil {
+			err = err1
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return o.layer.Chtimes(name, info.ModTime(), info.ModTime())
+}
+
+func copyFrom(fsys FS, name string, dst io.Writer) error {
+	src, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// copyUpTree 把目录及其中所有内容复制到上层，调用时需持有锁
+func (o *overlayFS) copyUpTree(name string) error {
+	info, inLayer, err := o.stat(name, true)
+	if err != nil {
+		return err
+	}
+	if !inLayer {
+		if err := o.copyUp(name, info, true); err != nil {
+			return err
+		}
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	entries, err := o.readDir(name)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := o.copyUpTree(filepath.Join(name, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.readDir(name)
+}
+
+// readDir 合并上下层的目录，调用时需持有锁
+func (o *overlayFS) readDir(name string) ([]fs.DirEntry, error) {
+	info, inLayer, err := o.stat(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	merged := map[string]fs.DirEntry{}
+	if !o.hidden(name) && !o.opaque[overlayKey(name)] {
+		if entries, err := o.base.ReadDir(name); err == nil {
+			for _, e := range entries {
+				if !o.deleted[overlayKey(filepath.Join(name, e.Name()))] {
+					merged[e.Name()] = e
+				}
+			}
+		}
+	}
+	if inLayer {
+		entries, err := o.layer.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			merged[e.Name()] = e
+		}
+	}
+	list := make([]fs.DirEntry, 0, len(merged))
+	for _, e := range merged {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+func (o *overlayFS) ReadFile(name string) ([]byte, error) {
+	o.mu.Lock()
+	info, inLayer, err := o.stat(name, false)
+	o.mu.Unlock()
+	switch {
+	case err != nil:
+		return nil, err
+	case info.IsDir():
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	case inLayer:
+		return o.layer.ReadFile(name)
+	}
+	return o.base.ReadFile(name)
+}
+
+func (o *overlayFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := o.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (o *overlayFS) Mkdir(name string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.mkdir(name, perm)
+}
+
+// mkdir 调用时需持有锁
+func (o *overlayFS) mkdir(name string, perm fs.FileMode) error {
+	if _, _, err := o.stat(name, true); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := o.ensureParent(name); err != nil {
+		return err
+	}
+	if err := o.layer.Mkdir(name, perm); err != nil {
+		return err
+	}
+	if key := overlayKey(name); o.deleted[key] {
+		delete(o.deleted, key)
+		o.opaque[key] = true
+	}
+	return nil
+}
+
+func (o *overlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.mkdirAll(name, perm)
+}
+
+func (o *overlayFS) mkdirAll(name string, perm fs.FileMode) error {
+	if info, _, err := o.stat(name, false); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(name); parent != name {
+		if err := o.mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	return o.mkdir(name, perm)
+}
+
+func (o *overlayFS) Remove(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, inLayer, err := o.stat(name, true)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+		}
+	}
+	inBase := o.baseVisible(name)
+	if inLayer {
+		if err := o.layer.Remove(name); err != nil {
+			return err
+		}
+	}
+	if inBase {
+		o.deleted[overlayKey(name)] = true
+	}
+	return nil
+}
+
+func (o *overlayFS) RemoveAll(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	inBase := o.baseVisible(name)
+	if err := o.layer.RemoveAll(name); err != nil {
+		return err
+	}
+	if inBase {
+		o.deleted[overlayKey(name)] = true
+	}
+	return nil
+}
+
+func (o *overlayFS) Rename(oldname, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	info, _, err := o.stat(oldname, true)
+	if err != nil {
+		return linkErr(fs.ErrNotExist)
+	}
+	if dst, _, err := o.stat(newname, true); err == nil {
+		switch {
+		case dst.IsDir() && !info.IsDir():
+			return linkErr(errIsDir)
+		case !dst.IsDir() && info.IsDir():
+			return linkErr(errNotDir)
+		case dst.IsDir():
+			if entries, err := o.readDir(newname); err != nil || len(entries) > 0 {
+				return linkErr(errNotEmpty)
+			}
+		}
+	}
+	if err := o.copyUpTree(oldname); err != nil {
+		return err
+	}
+	if err := o.ensureParent(newname); err != nil {
+		return err
+	}
+	inBase := o.baseVisible(oldname)
+	if err := o.layer.Rename(oldname, newname); err != nil {
+		return err
+	}
+	newKey := overlayKey(newname)
+	delete(o.deleted, newKey)
+	if info.IsDir() && o.baseVisible(newname) {
+		o.opaque[newKey] = true
+	}
+	if inBase {
+		o.deleted[overlayKey(oldname)] = true
+	}
+	return nil
+}
+
+func (o *overlayFS) Chmod(name string, mode fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, inLayer, err := o.stat(name, false)
+	if err != nil {
+		return err
+	}
+	if !inLayer {
+		if err := o.copyUp(name, info, true); err != nil {
+			return err
+		}
+	}
+	return o.layer.Chmod(name, mode)
+}
+
+func (o *overlayFS) Chtimes(name string, atime, mtime time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info, inLayer, err := o.stat(name, false)
+	if err != nil {
+		return err
+	}
+	if !inLayer {
+		if err := o.copyUp(name, info, true); err != nil {
+			return err
+		}
+	}
+	return o.layer.Chtimes(name, atime, mtime)
+}
+
+// overlayDir 合并后的目录
+type overlayDir struct {
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *overlayDir) pathErr(op string, err error) error {
+	return &fs.PathError{Op: op, Path: d.name, Err: err}
+}
+
+func (d *overlayDir) Name() string                      { return d.name }
+func (d *overlayDir) Stat() (fs.FileInfo, error)        { return d.info, nil }
+func (d *overlayDir) Read([]byte) (int, error)          { return 0, d.pathErr("read", errIsDir) }
+func (d *overlayDir) ReadAt([]byte, int64) (int, error) { return 0, d.pathErr("read", errIsDir) }
+func (d *overlayDir) Write([]byte) (int, error)         { return 0, d.pathErr("write", errBadFD) }
+func (d *overlayDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, d.pathErr("seek", errIsDir)
+}
+func (d *overlayDir) Sync() error          { return nil }
+func (d *overlayDir) Truncate(int64) error { return d.pathErr("truncate", errIsDir) }
+func (d *overlayDir) Close() error         { return nil }
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.pos:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.pos += len(entries)
+	return entries, nil
+}
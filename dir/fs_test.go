@@ -0,0 +1,459 @@
+package dir
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func writeFiles(t *testing.T, fsys FS, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if strings.HasSuffix(name, "/") {
+			if err := fsys.MkdirAll(name, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := fsys.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFS(t *testing.T, fsys FS, name string) string {
+	t.Helper()
+	b, err := fsys.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func dirNames(t *testing.T, fsys FS, name string) string {
+	t.Helper()
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// strictFS 按io/fs的规则拒绝非规范路径，FS的实现本身接受绝对路径等写法
+type strictFS struct {
+	FS
+}
+
+func (s strictFS) check(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+func (s strictFS) Open(name string) (fs.File, error) {
+	if err := s.check("open", name); err != nil {
+		return nil, err
+	}
+	return s.FS.Open(name)
+}
+
+func (s strictFS) Stat(name string) (fs.FileInfo, error) {
+	if err := s.check("stat", name); err != nil {
+		return nil, err
+	}
+	return s.FS.Stat(name)
+}
+
+func (s strictFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := s.check("readdir", name); err != nil {
+		return nil, err
+	}
+	return s.FS.ReadDir(name)
+}
+
+func (s strictFS) ReadFile(name string) ([]byte, error) {
+	if err := s.check("readfile", name); err != nil {
+		return nil, err
+	}
+	return s.FS.ReadFile(name)
+}
+
+func TestIOFSConformance(t *testing.T) {
+	m := NewMemFS()
+	writeFiles(t, m, map[string]string{
+		"a.txt":      "a",
+		"sub/b.txt":  "bb",
+		"sub/deep/c": "ccc",
+		"empty/":     "",
+	})
+	if err := fstest.TestFS(strictFS{m}, "a.txt", "sub/b.txt", "sub/deep/c", "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewOverlayFS(m, NewMemFS())
+	writeFiles(t, o, map[string]string{"sub/new.txt": "new", "a.txt": "changed"})
+	if err := o.Remove("sub/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(strictFS{o}, "a.txt", "sub/new.txt", "sub/deep/c", "empty"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testWritableFS 各实现共同的读写行为
+func testWritableFS(t *testing.T, fsys FS) {
+	writeFiles(t, fsys, map[string]string{"dir/a.txt": "hello", "dir/sub/b.txt": "b"})
+	if got := dirNames(t, fsys, "dir"); got != "a.txt,sub" {
+		t.Errorf("ReadDir = %s", got)
+	}
+
+	f, err := fsys.OpenFile("dir/a.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got := readFS(t, fsys, "dir/a.txt"); got != "hello world" {
+		t.Errorf("append = %q", got)
+	}
+
+	f, err = fsys.OpenFile("dir/a.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("WORLD"))
+	buf := make([]byte, 5)
+	if _, err := f.ReadAt(buf, 0); err != nil || string(buf) != "hello" {
+		t.Errorf("ReadAt = %q, %v", buf, err)
+	}
+	if err := f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if got := readFS(t, fsys, "dir/a.txt"); got != "hello" {
+		t.Errorf("after truncate = %q", got)
+	}
+
+	if _, err := fsys.OpenFile("dir/a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("O_EXCL err = %v", err)
+	}
+	if _, err := fsys.Stat("dir/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat missing err = %v", err)
+	}
+	if err := fsys.Remove("dir/sub"); err == nil {
+		t.Error("removed non-empty directory")
+	}
+
+	mtime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := fsys.Chtimes("dir/a.txt", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Chmod("dir/a.txt", 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fsys.Stat("dir/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0600 || info.Size() != 5 {
+		t.Errorf("stat = %v %v %d", info.ModTime(), info.Mode(), info.Size())
+	}
+
+	if err := fsys.Rename("dir/sub", "moved"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFS(t, fsys, "moved/b.txt"); got != "b" {
+		t.Errorf("renamed content = %q", got)
+	}
+	if _, err := fsys.Stat("dir/sub"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old name still exists: %v", err)
+	}
+	if err := fsys.RemoveAll("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.RemoveAll("dir"); err != nil {
+		t.Errorf("RemoveAll missing = %v", err)
+	}
+	if got := dirNames(t, fsys, "."); got != "moved" {
+		t.Errorf("root = %s", got)
+	}
+}
+
+func TestFSImplementations(t *testing.T) {
+	cases := map[string]func(t *testing.T) FS{
+		"mem":  func(t *testing.T) FS { return NewMemFS() },
+		"os":   func(t *testing.T) FS { return NewBasePathFS(OS, t.TempDir()) },
+		"base": func(t *testing.T) FS { return NewBasePathFS(NewMemFS(), "/jail") },
+		"overlay": func(t *testing.T) FS {
+			return NewOverlayFS(NewMemFS(), NewMemFS())
+		},
+		"overlay-os": func(t *testing.T) FS {
+			return NewOverlayFS(NewBasePathFS(OS, t.TempDir()), NewMemFS())
+		},
+	}
+	for name, newFS := range cases {
+		t.Run(name, func(t *testing.T) {
+			testWritableFS(t, newFS(t))
+		})
+	}
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	m := NewMemFS()
+	writeFiles(t, m, map[string]string{"a.txt": "a"})
+	ro := NewReadOnlyFS(m)
+	if got := readFS(t, ro, "a.txt"); got != "a" {
+		t.Errorf("read = %q", got)
+	}
+	f, err := ro.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	errs := []error{
+		ro.WriteFile("b.txt", nil, 0644),
+		ro.Mkdir("d", 0755),
+		ro.Remove("a.txt"),
+		ro.RemoveAll("a.txt"),
+		ro.Rename("a.txt", "c.txt"),
+		ro.Chmod("a.txt", 0600),
+	}
+	_, err = ro.OpenFile("a.txt", os.O_WRONLY, 0)
+	errs = append(errs, err)
+	for i, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("op %d err = %v", i, err)
+		}
+	}
+	if got := readFS(t, m, "a.txt"); got != "a" {
+		t.Errorf("underlying changed: %q", got)
+	}
+}
+
+func TestBasePathFS(t *testing.T) {
+	root := t.TempDir()
+	jail := filepath.Join(root, "jail")
+	makeTree(t, root, map[string]string{"secret.txt": "secret", "jail/in.txt": "in"})
+	b := NewBasePathFS(OS, jail)
+
+	if got := readFS(t, b, "/in.txt"); got != "in" {
+		t.Errorf("in.txt = %q", got)
+	}
+	_, err := b.ReadFile("../secret.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("escaped jail: %v", err)
+	}
+	if strings.Contains(err.Error(), root) {
+		t.Errorf("error leaks base path: %v", err)
+	}
+	if err := b.WriteFile("../../out.txt", []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsExist(filepath.Join(jail, "out.txt")) || IsExist(filepath.Join(root, "out.txt")) {
+		t.Error("write escaped jail")
+	}
+	f, err := b.OpenFile("in.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Name() != "in.txt" {
+		t.Errorf("Name = %s", f.Name())
+	}
+}
+
+func TestBasePathFSSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	root := t.TempDir()
+	jail := filepath.Join(root, "jail")
+	makeTree(t, root, map[string]string{"secret.txt": "secret", "jail/d/in.txt": "in"})
+	b := NewBasePathFS(OS, jail).(SymlinkFS)
+
+	if err := b.Symlink("in.txt", "d/ok"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.EvalSymlinks("d/ok"); err != nil || got != filepath.FromSlash("/d/in.txt") {
+		t.Errorf("EvalSymlinks = %q, %v", got, err)
+	}
+	// a -> .、a/b -> ..这类链式链接
+	if err := b.Symlink(".", "a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][2]string{
+		{filepath.Join(root, "secret.txt"), "abs"},
+		{"../secret.txt", "rel"},
+		{"../../secret.txt", "d/rel"},
+		{"..", "a/b"},
+	} {
+		err := b.Symlink(c[0], c[1])
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("Symlink(%s, %s) = %v", c[0], c[1], err)
+		}
+		if _, err := os.Lstat(filepath.Join(jail, c[1])); !os.IsNotExist(err) {
+			t.Errorf("%s created", c[1])
+		}
+	}
+
+	// base中已有的指向外部的链接
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(jail, "out")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.EvalSymlinks("out")
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("EvalSymlinks = %q, %v", got, err)
+	}
+	if strings.Contains(err.Error(), root) {
+		t.Errorf("error leaks host path: %v", err)
+	}
+}
+
+func TestOverlayFS(t *testing.T) {
+	base := NewMemFS()
+	writeFiles(t, base, map[string]string{
+		"etc/app.conf": "base",
+		"etc/other":    "other",
+		"data/1.txt":   "1",
+		"data/2.txt":   "2",
+	})
+	layer := NewMemFS()
+	o := NewOverlayFS(base, layer)
+
+	// 写入只修改上层
+	if err := o.WriteFile("etc/app.conf", []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFS(t, o, "etc/app.conf"); got != "changed" {
+		t.Errorf("overlay = %q", got)
+	}
+	if got := readFS(t, base, "etc/app.conf"); got != "base" {
+		t.Errorf("base modified: %q", got)
+	}
+	if got := dirNames(t, o, "etc"); got != "app.conf,other" {
+		t.Errorf("merged etc = %s", got)
+	}
+
+	// 追加时先复制下层内容
+	f, err := o.OpenFile("etc/other", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("+"))
+	f.Close()
+	if got := readFS(t, o, "etc/other"); got != "other+" {
+		t.Errorf("append = %q", got)
+	}
+
+	// 删除下层的文件
+	if err := o.Remove("data/1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, o, "data"); got != "2.txt" {
+		t.Errorf("after remove = %s", got)
+	}
+	if got := readFS(t, base, "data/1.txt"); got != "1" {
+		t.Error("base file removed")
+	}
+
+	// 删除后重新创建的目录不显示下层的内容
+	if err := o.RemoveAll("data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Mkdir("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, o, "data"); got != "" {
+		t.Errorf("recreated dir = %q", got)
+	}
+	if err := o.WriteFile("data/new.txt", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, o, "data"); got != "new.txt" {
+		t.Errorf("recreated dir = %q", got)
+	}
+
+	// 重命名下层的目录
+	if err := o.Rename("etc", "conf"); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, o, "."); got != "conf,data" {
+		t.Errorf("root = %s", got)
+	}
+	if got := readFS(t, o, "conf/other"); got != "other+" {
+		t.Errorf("renamed = %q", got)
+	}
+	if got := dirNames(t, base, "."); got != "data,etc" {
+		t.Errorf("base root = %s", got)
+	}
+}
+
+func TestHelperOnMemFS(t *testing.T) {
+	h := NewHelper(NewMemFS())
+	if _, err := h.WriteString("/app/conf/a.yaml", "name: test\n"); err != nil {
+		t.Fatal(err)
+	}
+	var conf struct{ Name string }
+	if err := h.ReadYaml("/app/conf/a.yaml", &conf); err != nil || conf.Name != "test" {
+		t.Fatalf("ReadYaml = %+v, %v", conf, err)
+	}
+	if err := h.AppendFile("/app/log.txt", []byte("line")); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := h.ReadString("/app/log.txt"); s != "line\r\n\r\n" {
+		t.Errorf("AppendFile = %q", s)
+	}
+	dirs, _ := h.DirsUnder("/app")
+	files, _ := h.FilesUnder("/app")
+	if strings.Join(dirs, ",") != "conf" || strings.Join(files, ",") != "log.txt" {
+		t.Errorf("DirsUnder = %v, FilesUnder = %v", dirs, files)
+	}
+
+	if err := h.CopyDir("/app", "/backup"); err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	if err := h.Walk("/backup", WalkOptions{}, func(e WalkEntry) error {
+		rels = append(rels, e.RelPath)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(rels, ","); got != "conf/a.yaml,log.txt" {
+		t.Errorf("copied = %s", got)
+	}
+	want, _ := h.Checksum("/app/conf/a.yaml", HashSHA256)
+	if got, err := h.Checksum("/backup/conf/a.yaml", HashSHA256); err != nil || got != want {
+		t.Errorf("checksum = %s, %v", got, err)
+	}
+	if err := h.Move("/backup", "/old/backup"); err != nil {
+		t.Fatal(err)
+	}
+	if h.IsExist("/backup") || !h.IsFile("/old/backup/log.txt") {
+		t.Error("move failed")
+	}
+	// 包级函数不受影响
+	if IsExist("/app/log.txt") {
+		t.Error("memfs file visible on OS")
+	}
+}
@@ -0,0 +1,252 @@
+package dir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// readOnlyFS 只读文件系统，所有写操作返回ErrReadOnly
+type readOnlyFS struct {
+	FS
+}
+
+// NewReadOnlyFS 包装fsys，禁止写操作
+func NewReadOnlyFS(fsys FS) FS {
+	return readOnlyFS{FS: fsys}
+}
+
+func readOnlyErr(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+func (r readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, readOnlyErr("open", name)
+	}
+	return r.FS.OpenFile(name, flag, perm)
+}
+
+func (r readOnlyFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return readOnlyErr("open", name)
+}
+
+func (r readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return readOnlyErr("mkdir", name)
+}
+
+func (r readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return readOnlyErr("mkdir", name)
+}
+
+func (r readOnlyFS) Remove(name string) error {
+	return readOnlyErr("remove", name)
+}
+
+func (r readOnlyFS) RemoveAll(name string) error {
+	return readOnlyErr("remove", name)
+}
+
+func (r readOnlyFS) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: ErrReadOnly}
+}
+
+func (r readOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return readOnlyErr("chmod", name)
+}
+
+func (r readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return readOnlyErr("chtimes", name)
+}
+
+func (r readOnlyFS) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrReadOnly}
+}
+
+func (r readOnlyFS) Readlink(name string) (string, error) {
+	return readlink(r.FS, name)
+}
+
+func (r readOnlyFS) EvalSymlinks(name string) (string, error) {
+	return evalSymlinks(r.FS, name)
+}
+
+// basePathFS 把所有路径限制在base目录下
+type basePathFS struct {
+	fs   FS
+	base string
+}
+
+// NewBasePathFS 以base为根目录访问fsys，路径中的..不能越过base
+// 不能创建指向外部的符号链接，但base中已有的指向外部的链接仍然可以访问外部文件
+func NewBasePathFS(fsys FS, base string) FS {
+	return &basePathFS{fs: fsys, base: filepath.Clean(base)}
+}
+
+// real 转换为fs中的路径
+func (b *basePathFS) real(name string) string {
+	return filepath.Join(b.base, filepath.Clean(string(filepath.Separator)+filepath.FromSlash(name)))
+}
+
+// rel 转换回base下的路径，以/开头
+func (b *basePathFS) rel(real string) string {
+	return relTo(b.base, real)
+}
+
+func relTo(base, real string) string {
+	sep := string(filepath.Separator)
+	if real == base {
+		return sep
+	}
+	if prefix := strings.TrimSuffix(base, sep) + sep; strings.HasPrefix(real, prefix) {
+		return real[len(prefix)-1:]
+	}
+	return real
+}
+
+// err 错误中不暴露base
+func (b *basePathFS) err(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: b.rel(pe.Path), Err: pe.Err}
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &os.LinkError{Op: le.Op, Old: b.rel(le.Old), New: b.rel(le.New), Err: le.Err}
+	}
+	return err
+}
+
+func (b *basePathFS) Open(name string) (fs.File, error) {
+	f, err := b.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (b *basePathFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := b.fs.OpenFile(b.real(name), flag, perm)
+	if err != nil {
+		return nil, b.err(err)
+	}
+	return basePathFile{File: f, name: name}, nil
+}
+
+func (b *basePathFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := b.fs.Stat(b.real(name))
+	return info, b.err(err)
+}
+
+func (b *basePathFS) Lstat(name string) (fs.FileInfo, error) {
+	info, err := b.fs.Lstat(b.real(name))
+	return info, b.err(err)
+}
+
+func (b *basePathFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := b.fs.ReadDir(b.real(name))
+	return entries, b.err(err)
+}
+
+func (b *basePathFS) ReadFile(name string) ([]byte, error) {
+	data, err := b.fs.ReadFile(b.real(name))
+	return data, b.err(err)
+}
+
+func (b *basePathFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return b.err(b.fs.WriteFile(b.real(name), data, perm))
+}
+
+func (b *basePathFS) Mkdir(name string, perm fs.FileMode) error {
+	return b.err(b.fs.Mkdir(b.real(name), perm))
+}
+
+func (b *basePathFS) MkdirAll(name string, perm fs.FileMode) error {
+	return b.err(b.fs.MkdirAll(b.real(name), perm))
+}
+
+func (b *basePathFS) Remove(name string) error {
+	return b.err(b.fs.Remove(b.real(name)))
+}
+
+func (b *basePathFS) RemoveAll(name string) error {
+	if b.real(name) == b.base {
+		// 不删除base本身
+		entries, err := b.fs.ReadDir(b.base)
+		if err != nil {
+			return b.err(err)
+		}
+		for _, e := range entries {
+			if err := b.fs.RemoveAll(filepath.Join(b.base, e.Name())); err != nil {
+				return b.err(err)
+			}
+		}
+		return nil
+	}
+	return b.err(b.fs.RemoveAll(b.real(name)))
+}
+
+func (b *basePathFS) Rename(oldname, newname string) error {
+	return b.err(b.fs.Rename(b.real(oldname), b.real(newname)))
+}
+
+func (b *basePathFS) Chmod(name string, mode fs.FileMode) error {
+	return b.err(b.fs.Chmod(b.real(name), mode))
+}
+
+func (b *basePathFS) Chtimes(name string, atime, mtime time.Time) error {
+	return b.err(b.fs.Chtimes(b.real(name), atime, mtime))
+}
+
+// Symlink oldname原样保存，不做转换
+// 绝对路径和解析后指向base外部的链接返回ErrUnsafePath
+func (b *basePathFS) Symlink(oldname, newname string) error {
+	p := b.real(newname)
+	root, err := evalSymlinks(b.fs, b.base)
+	if err != nil {
+		return b.err(err)
+	}
+	ok, err := linkWithin(b.fs, root, filepath.Dir(p), oldname)
+	if err != nil {
+		return b.err(err)
+	}
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrUnsafePath}
+	}
+	return b.err(symlink(b.fs, oldname, p))
+}
+
+func (b *basePathFS) Readlink(name string) (string, error) {
+	target, err := readlink(b.fs, b.real(name))
+	return target, b.err(err)
+}
+
+// EvalSymlinks 解析到base外部时返回ErrUnsafePath，不暴露外部路径
+func (b *basePathFS) EvalSymlinks(name string) (string, error) {
+	resolved, err := evalSymlinks(b.fs, b.real(name))
+	if err != nil {
+		return "", b.err(err)
+	}
+	// base本身可能包含符号链接
+	base, err := evalSymlinks(b.fs, b.base)
+	if err != nil {
+		return "", b.err(err)
+	}
+	if !inRoot(base, resolved) {
+		return "", &fs.PathError{Op: "evalsymlinks", Path: name, Err: ErrUnsafePath}
+	}
+	return relTo(base, resolved), nil
+}
+
+// basePathFile Name返回打开时使用的路径
+type basePathFile struct {
+	File
+	name string
+}
+
+func (f basePathFile) Name() string {
+	return f.name
+}
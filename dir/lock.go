@@ -0,0 +1,252 @@
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockMode 文件锁的模式
+type LockMode int
+
+const (
+	LockShared    LockMode = iota // 共享锁，可以有多个持有者，用于读
+	LockExclusive                 // 排他锁，用于写
+)
+
+var (
+	ErrLocked         = errors.New("dir: file is locked by another process")
+	ErrAlreadyRunning = errors.New("dir: another instance is running")
+)
+
+// FileLock 进程间的建议锁，Linux和macOS上使用flock，Windows上使用LockFileEx
+// 锁属于打开的文件，同一进程中对同一文件多次加锁也会互相等待
+type FileLock struct {
+	f    *os.File
+	mode LockMode
+}
+
+// Lock 对path加锁，文件不存在时创建
+// timeout为0时只尝试一次，小于0时一直等待，超时返回ErrLocked
+func Lock(path string, mode LockMode, timeout time.Duration) (*FileLock, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	} else if timeout == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		cancel()
+	}
+	return LockContext(ctx, path, mode)
+}
+
+// LockContext 对path加锁直到成功或ctx结束，ctx结束时返回ErrLocked
+func LockContext(ctx context.Context, path string, mode LockMode) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(ctx, f, mode); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileLock{f: f, mode: mode}, nil
+}
+
+// lockFile 轮询非阻塞的加锁，间隔从1ms逐步增加到100ms
+func lockFile(ctx context.Context, f *os.File, mode LockMode) error {
+	delay := time.Millisecond
+	for {
+		ok, err := tryLock(f, mode)
+		if err != nil {
+			return &os.PathError{Op: "lock", Path: f.Name(), Err: err}
+		}
+		if ok {
+			return nil
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return &os.PathError{Op: "lock", Path: f.Name(), Err: ErrLocked}
+		case <-t.C:
+		}
+		if delay *= 2; delay > 100*time.Millisecond {
+			delay = 100 * time.Millisecond
+		}
+	}
+}
+
+// File 加锁的文件，关闭请使用Unlock
+func (l *FileLock) File() *os.File {
+	return l.f
+}
+
+// Mode 加锁的模式
+func (l *FileLock) Mode() LockMode {
+	return l.mode
+}
+
+// Unlock 释放锁并关闭文件
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// AppendFileLocked 加排他锁后追加b和分隔符sep，多个进程同时追加时记录不会交错
+func AppendFileLocked(filePath string, b []byte, sep string) error {
+	return std.AppendFileLocked(filePath, b, sep)
+}
+
+// appendMu 文件系统不是操作系统的文件时，只能在当前进程内互斥
+var appendMu sync.Mutex
+
+func (h *Helper) AppendFileLocked(filePath string, b []byte, sep string) error {
+	if err := h.fs.MkdirAll(path.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := h.fs.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	// 通过加锁的同一个句柄写入，Windows上的锁是强制的，其他句柄无法写入
+	of, locked := osFile(f)
+	if locked {
+		if err := lockFile(context.Background(), of, LockExclusive); err != nil {
+			f.Close()
+			return err
+		}
+	} else {
+		appendMu.Lock()
+	}
+	// 一次写入，和不加锁的O_APPEND写入者之间也不会交错
+	_, err = f.Write(append(b[:len(b):len(b)], sep...))
+	if locked {
+		if uerr := unlockFile(of); err == nil {
+			err = uerr
+		}
+	} else {
+		appendMu.Unlock()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// osFile 取出文件系统包装的*os.File
+func osFile(f File) (*os.File, bool) {
+	switch f := f.(type) {
+	case *os.File:
+		return f, true
+	case basePathFile:
+		return osFile(f.File)
+	}
+	return nil, false
+}
+
+// PIDFile 防止同一程序启动多个实例，持有期间对文件加排他锁
+type PIDFile struct {
+	path string
+	lock *FileLock
+}
+
+// CreatePIDFile 写入当前进程的PID
+// 其他实例正在运行时返回ErrAlreadyRunning；文件中的PID对应的进程已经退出时覆盖该文件
+func CreatePIDFile(path string) (*PIDFile, error) {
+	for {
+		l, err := Lock(path, LockExclusive, 0)
+		if errors.Is(err, ErrLocked) {
+			if pid, rerr := ReadPIDFile(path); rerr == nil {
+				return nil, fmt.Errorf("%w: pid %d", ErrAlreadyRunning, pid)
+			}
+			return nil, ErrAlreadyRunning
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 加锁期间文件可能被上一个持有者删除，此时锁在已删除的文件上，需要重新加锁
+		if same, err := sameFile(l.f, path); err != nil || !same {
+			l.Unlock()
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		// 没有使用锁的旧版本进程也能检测到
+		if pid, err := readPID(l.f); err == nil && pid != os.Getpid() && ProcessAlive(pid) {
+			l.Unlock()
+			return nil, fmt.Errorf("%w: pid %d", ErrAlreadyRunning, pid)
+		}
+		if err := writePID(l.f); err != nil {
+			l.Unlock()
+			return nil, err
+		}
+		return &PIDFile{path: path, lock: l}, nil
+	}
+}
+
+func sameFile(f *os.File, path string) (bool, error) {
+	a, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	b, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(a, b), nil
+}
+
+func readPID(f *os.File) (int, error) {
+	buf := make([]byte, 32)
+	n, err := f.ReadAt(buf, 0)
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+}
+
+func writePID(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Path PID文件的路径
+func (p *PIDFile) Path() string {
+	return p.path
+}
+
+// Remove 删除PID文件并释放锁，程序退出前调用
+func (p *PIDFile) Remove() error {
+	return removeLocked(p.path, p.lock)
+}
+
+// ReadPIDFile 读取PID文件中的进程号
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("dir: invalid pid file %s: %w", path, err)
+	}
+	return pid, nil
+}
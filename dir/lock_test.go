@@ -0,0 +1,172 @@
+package dir
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "lock")
+	l, err := Lock(p, LockExclusive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(p, LockExclusive, 20*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("err = %v, want ErrLocked", err)
+	}
+	if _, err := Lock(p, LockShared, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("err = %v, want ErrLocked", err)
+	}
+	// 等待中的加锁在释放后成功
+	done := make(chan error)
+	go func() {
+		l2, err := Lock(p, LockExclusive, 5*time.Second)
+		if err == nil {
+			err = l2.Unlock()
+		}
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	s1, err := Lock(p, LockShared, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := Lock(p, LockShared, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(p, LockExclusive, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("err = %v, want ErrLocked", err)
+	}
+	s1.Unlock()
+	s2.Unlock()
+}
+
+func TestAppendFileLocked(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "logs", "app.log")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				rec := strings.Repeat(fmt.Sprint(i), 1000)
+				if err := AppendFileLocked(p, []byte(rec), "\n"); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	data, _ := os.ReadFile(p)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 160 {
+		t.Fatalf("%d lines", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) != 1000 || strings.Count(line, line[:1]) != 1000 {
+			t.Fatalf("interleaved record %q", line[:20])
+		}
+	}
+}
+
+func TestAppendFileLockedHelper(t *testing.T) {
+	for name, fsys := range map[string]FS{"mem": NewMemFS(), "base": NewBasePathFS(OS, t.TempDir())} {
+		h := NewHelper(fsys)
+		for _, rec := range []string{"a", "b"} {
+			if err := h.AppendFileLocked("/logs/app.log", []byte(rec), "\n"); err != nil {
+				t.Fatal(name, err)
+			}
+		}
+		if s, err := h.ReadString("/logs/app.log"); err != nil || s != "a\nb\n" {
+			t.Fatalf("%s: %q, %v", name, s, err)
+		}
+	}
+}
+
+// TestPIDFileHelper 作为子进程运行，创建PID文件后等待stdin关闭
+func TestPIDFileHelper(t *testing.T) {
+	p := os.Getenv("DIR_TEST_PIDFILE")
+	if p == "" {
+		t.Skip("helper process")
+	}
+	pf, err := CreatePIDFile(p)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("ready")
+	io.Copy(io.Discard, os.Stdin)
+	pf.Remove()
+	os.Exit(0)
+}
+
+func TestPIDFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.pid")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPIDFileHelper$")
+	cmd.Env = append(os.Environ(), "DIR_TEST_PIDFILE="+p)
+	stdin, _ := cmd.StdinPipe()
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "ready\n" {
+		t.Fatalf("helper: %q", line)
+	}
+	_, err := CreatePIDFile(p)
+	if !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("err = %v, want ErrAlreadyRunning", err)
+	}
+	if pid, _ := ReadPIDFile(p); pid != cmd.Process.Pid {
+		t.Fatalf("pid = %d, want %d", pid, cmd.Process.Pid)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(p) {
+		t.Fatal("pid file not removed")
+	}
+	if ProcessAlive(cmd.Process.Pid) {
+		t.Fatal("exited process reported alive")
+	}
+
+	// 进程已退出的PID文件被覆盖
+	os.WriteFile(p, []byte(fmt.Sprintln(cmd.Process.Pid)), 0644)
+	pf, err := CreatePIDFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, _ := ReadPIDFile(p); pid != os.Getpid() {
+		t.Fatalf("pid = %d", pid)
+	}
+	if _, err := CreatePIDFile(p); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("err = %v", err)
+	}
+	if err := pf.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有加锁但进程仍在运行
+	os.WriteFile(p, []byte(fmt.Sprintln(os.Getppid())), 0644)
+	if _, err := CreatePIDFile(p); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("err = %v", err)
+	}
+}
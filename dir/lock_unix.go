@@ -0,0 +1,51 @@
+//go:build !windows
+
+package dir
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock 非阻塞的flock，已被其他持有者锁住时返回false
+func tryLock(f *os.File, mode LockMode) (bool, error) {
+	how := syscall.LOCK_SH
+	if mode == LockExclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, syscall.EWOULDBLOCK):
+			return false, nil
+		case errors.Is(err, syscall.EINTR):
+			continue
+		}
+		return false, err
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// removeLocked 先删除再解锁，等待锁的进程拿到锁后会发现文件已被删除
+func removeLocked(path string, l *FileLock) error {
+	err := os.Remove(path)
+	if uerr := l.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// ProcessAlive 进程是否存在，没有权限发送信号的进程也认为存在
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package dir
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLock 非阻塞的LockFileEx，锁住整个文件，已被其他持有者锁住时返回false
+func tryLock(f *os.File, mode LockMode) (bool, error) {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if mode == LockExclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, ^uint32(0), ^uint32(0), new(windows.Overlapped))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, windows.ERROR_LOCK_VIOLATION), errors.Is(err, windows.ERROR_IO_PENDING):
+		return false, nil
+	}
+	return false, err
+}
+
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, ^uint32(0), ^uint32(0), new(windows.Overlapped))
+}
+
+// removeLocked Windows上打开的文件不能删除，先解锁关闭再删除
+func removeLocked(path string, l *FileLock) error {
+	err := l.Unlock()
+	if rerr := os.Remove(path); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// stillActive GetExitCodeProcess对运行中的进程返回STILL_ACTIVE
+const stillActive = 259
+
+// ProcessAlive 进程是否存在，没有权限打开的进程也认为存在
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(h)
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
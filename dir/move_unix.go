@@ -0,0 +1,13 @@
+//go:build !windows
+
+package dir
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice rename跨文件系统时返回EXDEV
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package dir
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice ERROR_NOT_SAME_DEVICE
+const errorNotSameDevice = syscall.Errno(17)
+
+// isCrossDevice MoveFileEx跨盘符时返回ERROR_NOT_SAME_DEVICE
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
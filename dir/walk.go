@@ -0,0 +1,462 @@
+package dir
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymlinkPolicy 遍历时对符号链接的处理
+type SymlinkPolicy int
+
+const (
+	SymlinkSkip    SymlinkPolicy = iota // 跳过符号链接
+	SymlinkInclude                      // 作为普通条目返回链接本身，不进入链接指向的目录
+	SymlinkFollow                       // 跟随链接，指向目录时进入该目录，已经遍历过的目录不会重复进入
+)
+
+// WalkOptions 遍历选项
+// Include、Exclude和忽略文件使用.gitignore的规则：不含/的模式匹配任意层级的文件名，
+// 含/的模式相对根目录匹配，**匹配任意层级，以/结尾的模式只匹配目录
+type WalkOptions struct {
+	Include        []string      // 只返回匹配的文件，为空时返回所有文件
+	Exclude        []string      // 排除匹配的文件和目录，目录被排除时不再进入
+	IgnoreFiles    []string      // 每个目录中读取的忽略文件名，例如.gitignore，支持!取反
+	MaxDepth       int           // 最大深度，根目录下的条目深度为1，0不限制
+	Symlinks       SymlinkPolicy // 默认跳过符号链接
+	IncludeDirs    bool          // 是否同时返回目录
+	MinSize        int64         // 文件最小字节数，0不限制
+	MaxSize        int64         // 文件最大字节数，0不限制
+	ModifiedAfter  time.Time     // 只返回在此之后修改的文件
+	ModifiedBefore time.Time     // 只返回在此之前修改的文件
+	// OnError 读取目录出错时调用，返回nil继续遍历；为空时遍历终止并返回该错误
+	OnError func(path string, err error) error
+	Workers int // WalkConcurrent的并发数，默认为4
+}
+
+// WalkEntry 遍历得到的文件或目录
+type WalkEntry struct {
+	Path    string      // 完整路径
+	RelPath string      // 相对根目录的路径，使用/分隔
+	Depth   int         // 根目录下的条目为1
+	Info    os.FileInfo // SymlinkFollow时为链接指向的文件信息
+}
+
+// WalkResult WalkConcurrent的结果，Err不为空时Entry.Path为出错的路径
+type WalkResult struct {
+	Entry WalkEntry
+	Err   error
+}
+
+type dirTask struct {
+	path  string
+	rel   string
+	depth int
+	rules []ignoreRule
+}
+
+type walkItem struct {
+	entry   WalkEntry
+	isDir   bool
+	report  bool
+	descend bool
+}
+
+type walker struct {
+	fs      FS
+	opts    WalkOptions
+	include []ignoreRule
+	exclude []ignoreRule
+
+	mu      sync.Mutex
+	visited map[string]bool // SymlinkFollow时已遍历的目录(解析后的路径)
+}
+
+func newWalker(fsys FS, root string, opts WalkOptions) *walker {
+	w := &walker{fs: fsys, opts: opts, visited: map[string]bool{}}
+	for _, p := range opts.Include {
+		w.include = append(w.include, parseIgnoreRule("", p))
+	}
+	for _, p := range opts.Exclude {
+		w.exclude = append(w.exclude, parseIgnoreRule("", p))
+	}
+	if opts.Symlinks == SymlinkFollow {
+		if resolved, err := evalSymlinks(fsys, root); err == nil {
+			w.visited[resolved] = true
+		}
+	}
+	return w
+}
+
+// Walk 按字典序深度优先遍历root，fn返回filepath.SkipDir时不进入该目录
+// 对文件返回filepath.SkipDir时跳过所在目录中剩余的条目，同filepath.WalkDir
+func Walk(root string, opts WalkOptions, fn func(e WalkEntry) error) error {
+	return std.Walk(root, opts, fn)
+}
+
+func (h *Helper) Walk(root string, opts WalkOptions, fn func(e WalkEntry) error) error {
+	w := newWalker(h.fs, root, opts)
+	err := w.walk(dirTask{path: root}, fn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (w *walker) walk(t dirTask, fn func(e WalkEntry) error) error {
+	items, rules, err := w.readDir(t)
+	if err != nil {
+		if w.opts.OnError == nil {
+			return err
+		}
+		if err = w.opts.OnError(t.path, err); err != nil {
+			return err
+		}
+	}
+	for _, it := range items {
+		if it.report {
+			if err := fn(it.entry); err != nil {
+				if err == filepath.SkipDir {
+					if it.isDir {
+						continue
+					}
+					// 跳过当前目录中剩余的条目，上层目录继续遍历
+					return nil
+				}
+				return err
+			}
+		}
+		if it.descend {
+			if err := w.walk(dirTask{path: it.entry.Path, rel: it.entry.RelPath, depth: it.entry.Depth, rules: rules}, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readDir 读取目录并过滤，返回条目和该目录生效的忽略规则
+func (w *walker) readDir(t dirTask) ([]walkItem, []ignoreRule, error) {
+	rules := t.rules
+	for _, name := range w.opts.IgnoreFiles {
+		loaded, err := loadIgnoreFile(w.fs, filepath.Join(t.path, name), t.rel)
+		if err != nil {
+			return nil, rules, err
+		}
+		if len(loaded) > 0 {
+			rules = append(rules[:len(rules):len(rules)], loaded...)
+		}
+	}
+	entries, err := w.fs.ReadDir(t.path)
+	if err != nil {
+		return nil, rules, err
+	}
+	items := make([]walkItem, 0, len(entries))
+	for _, e := range entries {
+		if it, ok := w.item(t, e, rules); ok {
+			items = append(items, it)
+		}
+	}
+	return items, rules, nil
+}
+
+func (w *walker) item(t dirTask, e fs.DirEntry, rules []ignoreRule) (walkItem, bool) {
+	rel := e.Name()
+	if t.rel != "" {
+		rel = t.rel + "/" + rel
+	}
+	full := filepath.Join(t.path, e.Name())
+	info, err := e.Info()
+	if err != nil {
+		return walkItem{}, false
+	}
+	isDir := info.IsDir()
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch w.opts.Symlinks {
+		case SymlinkSkip:
+			return walkItem{}, false
+		case SymlinkFollow:
+			if info, err = w.fs.Stat(full); err != nil {
+				// 悬空的链接
+				return walkItem{}, false
+			}
+			isDir = info.IsDir()
+		}
+	}
+	depth := t.depth + 1
+	if w.opts.MaxDepth > 0 && depth > w.opts.MaxDepth {
+		return walkItem{}, false
+	}
+	if matchAny(w.exclude, rel, isDir) || matchRules(rules, rel, isDir) {
+		return walkItem{}, false
+	}
+	it := walkItem{entry: WalkEntry{Path: full, RelPath: rel, Depth: depth, Info: info}, isDir: isDir}
+	if isDir {
+		it.report = w.opts.IncludeDirs
+		it.descend = (w.opts.MaxDepth == 0 || depth < w.opts.MaxDepth) && w.enter(full)
+		return it, it.report || it.descend
+	}
+	if len(w.include) > 0 && !matchAny(w.include, rel, false) {
+		return walkItem{}, false
+	}
+	if (w.opts.MinSize > 0 && info.Size() < w.opts.MinSize) || (w.opts.MaxSize > 0 && info.Size() > w.opts.MaxSize) {
+		return walkItem{}, false
+	}
+	if (!w.opts.ModifiedAfter.IsZero() && !info.ModTime().After(w.opts.ModifiedAfter)) ||
+		(!w.opts.ModifiedBefore.IsZero() && !info.ModTime().Before(w.opts.ModifiedBefore)) {
+		return walkItem{}, false
+	}
+	it.report = true
+	return it, true
+}
+
+// enter 跟随符号链接时避免循环和重复进入同一个目录
+func (w *walker) enter(dir string) bool {
+	if w.opts.Symlinks != SymlinkFollow {
+		return true
+	}
+	resolved, err := evalSymlinks(w.fs, dir)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited[resolved] {
+		return false
+	}
+	w.visited[resolved] = true
+	return true
+}
+
+// WalkConcurrent 多个goroutine并发读取目录，结果无序；ctx取消后停止遍历并关闭channel
+func WalkConcurrent(ctx context.Context, root string, opts WalkOptions) <-chan WalkResult {
+	return std.WalkConcurrent(ctx, root, opts)
+}
+
+func (h *Helper) WalkConcurrent(ctx context.Context, root string, opts WalkOptions) <-chan WalkResult {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	w := newWalker(h.fs, root, opts)
+	out := make(chan WalkResult, workers*16)
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	q.push(dirTask{path: root})
+
+	send := func(r WalkResult) bool {
+		select {
+		case out <- r:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				t, ok := q.pop()
+				if !ok {
+					return
+				}
+				if ctx.Err() != nil {
+					q.done()
+					continue
+				}
+				items, rules, err := w.readDir(t)
+				if err != nil {
+					send(WalkResult{Entry: WalkEntry{Path: t.path, RelPath: t.rel, Depth: t.depth}, Err: err})
+				}
+				for _, it := range items {
+					if it.report && !send(WalkResult{Entry: it.entry}) {
+						break
+					}
+					if it.descend {
+						q.push(dirTask{path: it.entry.Path, rel: it.entry.RelPath, depth: it.entry.Depth, rules: rules})
+					}
+				}
+				q.done()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// dirQueue 无界队列，pending为已入队但未处理完的目录数，为0时所有worker退出
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tasks   []dirTask
+	pending int
+}
+
+func (q *dirQueue) push(t dirTask) {
+	q.mu.Lock()
+	q.tasks = append(q.tasks, t)
+	q.pending++
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *dirQueue) pop() (dirTask, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.tasks) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+	if len(q.tasks) == 0 {
+		return dirTask{}, false
+	}
+	t := q.tasks[len(q.tasks)-1]
+	q.tasks = q.tasks[:len(q.tasks)-1]
+	return t, true
+}
+
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	finished := q.pending == 0
+	q.mu.Unlock()
+	if finished {
+		q.cond.Broadcast()
+	}
+}
+
+// Glob 返回root下匹配pattern的文件路径，pattern规则同WalkOptions.Include
+func Glob(root, pattern string) ([]string, error) {
+	return std.Glob(root, pattern)
+}
+
+func (h *Helper) Glob(root, pattern string) ([]string, error) {
+	var files []string
+	err := h.Walk(root, WalkOptions{Include: []string{pattern}}, func(e WalkEntry) error {
+		files = append(files, e.Path)
+		return nil
+	})
+	return files, err
+}
+
+// MatchGlob 判断使用/分隔的相对路径是否匹配pattern，**匹配任意层级
+func MatchGlob(pattern, relPath string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(relPath, "/"))
+}
+
+// ignoreRule 一条.gitignore规则
+type ignoreRule struct {
+	base     string   // 忽略文件所在目录(相对根目录)，规则只作用于该目录下
+	segments []string // 按/分割的模式
+	negate   bool
+	dirOnly  bool
+}
+
+func parseIgnoreRule(base, line string) ignoreRule {
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// 不含/的模式匹配任意层级
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	r.segments = strings.Split(line, "/")
+	if !anchored {
+		r.segments = append([]string{"**"}, r.segments...)
+	}
+	return r
+}
+
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchAny 不处理取反，用于Include/Exclude
+func matchAny(rules []ignoreRule, rel string, isDir bool) bool {
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules 最后一条匹配的规则生效
+func matchRules(rules []ignoreRule, rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segs[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:])
+}
+
+// loadIgnoreFile 文件不存在时返回空
+func loadIgnoreFile(fsys FS, file, base string) ([]ignoreRule, error) {
+	f, err := fsys.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, parseIgnoreRule(base, line))
+	}
+	return rules, s.Err()
+}
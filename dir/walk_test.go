@@ -0,0 +1,201 @@
+package dir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeTree 按相对路径创建文件，以/结尾的为目录
+func makeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walkRel(t *testing.T, root string, opts WalkOptions) string {
+	t.Helper()
+	var got []string
+	if err := Walk(root, opts, func(e WalkEntry) error {
+		got = append(got, e.RelPath)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(got, ",")
+}
+
+func TestWalkFilters(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		"a.go":            "package a",
+		"a_test.go":       "package a",
+		"README.md":       "readme",
+		"cmd/main.go":     "package main",
+		"cmd/big.bin":     strings.Repeat("x", 2048),
+		"vendor/x/x.go":   "package x",
+		"internal/b/b.go": "package b",
+		"empty/":          "",
+	})
+
+	cases := []struct {
+		name string
+		opts WalkOptions
+		want string
+	}{
+		{"all", WalkOptions{}, "README.md,a.go,a_test.go,cmd/big.bin,cmd/main.go,internal/b/b.go,vendor/x/x.go"},
+		{"include", WalkOptions{Include: []string{"*.go"}, Exclude: []string{"*_test.go", "vendor/"}}, "a.go,cmd/main.go,internal/b/b.go"},
+		{"anchored **", WalkOptions{Include: []string{"internal/**/*.go"}}, "internal/b/b.go"},
+		{"depth", WalkOptions{MaxDepth: 1, IncludeDirs: true}, "README.md,a.go,a_test.go,cmd,empty,internal,vendor"},
+		{"size", WalkOptions{MinSize: 1024}, "cmd/big.bin"},
+	}
+	for _, c := range cases {
+		if got := walkRel(t, root, c.opts); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filepath.Join(root, "README.md"), old, old)
+	if got := walkRel(t, root, WalkOptions{ModifiedBefore: time.Now().Add(-time.Minute)}); got != "README.md" {
+		t.Errorf("mtime: got %s", got)
+	}
+}
+
+func TestWalkIgnoreFile(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		".gitignore":          "# comment\n*.log\nbuild/\n/top.txt\n",
+		"top.txt":             "",
+		"sub/top.txt":         "",
+		"app.log":             "",
+		"build/out":           "",
+		"sub/.gitignore":      "!keep.log\n",
+		"sub/keep.log":        "",
+		"sub/drop.log":        "",
+		"sub/build/generated": "",
+	})
+	got := walkRel(t, root, WalkOptions{IgnoreFiles: []string{".gitignore"}})
+	if got != ".gitignore,sub/.gitignore,sub/keep.log,sub/top.txt" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestWalkSkipDirAndSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink")
+	}
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"a/1.txt": "", "b/2.txt": ""})
+	// 指向上级目录的循环链接
+	_ = os.Symlink("..", filepath.Join(root, "a", "loop"))
+	_ = os.Symlink("b", filepath.Join(root, "c"))
+
+	if got := walkRel(t, root, WalkOptions{}); got != "a/1.txt,b/2.txt" {
+		t.Errorf("skip: %s", got)
+	}
+	if got := walkRel(t, root, WalkOptions{Symlinks: SymlinkInclude}); got != "a/1.txt,a/loop,b/2.txt,c" {
+		t.Errorf("include: %s", got)
+	}
+	// 跟随链接，c指向的b已经遍历过(或将被遍历)只进入一次，loop指向根目录不会再次进入
+	if got := walkRel(t, root, WalkOptions{Symlinks: SymlinkFollow}); got != "a/1.txt,b/2.txt" {
+		t.Errorf("follow: %s", got)
+	}
+
+	var got []string
+	_ = Walk(root, WalkOptions{IncludeDirs: true}, func(e WalkEntry) error {
+		got = append(got, e.RelPath)
+		if e.RelPath == "a" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if strings.Join(got, ",") != "a,b,b/2.txt" {
+		t.Errorf("SkipDir: %v", got)
+	}
+
+	// 对文件返回SkipDir跳过同一目录中剩余的条目，其他目录继续遍历
+	makeTree(t, root, map[string]string{"a/0.txt": "", "a/2.txt": ""})
+	got = nil
+	err := Walk(root, WalkOptions{}, func(e WalkEntry) error {
+		got = append(got, e.RelPath)
+		if e.RelPath == "a/0.txt" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil || strings.Join(got, ",") != "a/0.txt,b/2.txt" {
+		t.Errorf("SkipDir on file: %v, %v", got, err)
+	}
+}
+
+func TestWalkConcurrent(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	var want []string
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			name := filepath.ToSlash(filepath.Join("d"+string(rune('a'+i)), "sub", "f"+string(rune('0'+j))+".txt"))
+			files[name] = ""
+			want = append(want, name)
+		}
+	}
+	files["skip.tmp"] = ""
+	makeTree(t, root, files)
+
+	var got []string
+	for r := range WalkConcurrent(context.Background(), root, WalkOptions{Include: []string{"*.txt"}, Workers: 8}) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		got = append(got, r.Entry.RelPath)
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+
+	// 取消后channel关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := WalkConcurrent(ctx, root, WalkOptions{Workers: 2})
+	<-ch
+	cancel()
+	for range ch {
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, c := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"**/*.go", "a/b/c.go", true},
+		{"**/*.go", "c.go", true},
+		{"a/**/c.go", "a/c.go", true},
+		{"a/*/c.go", "a/x/y/c.go", false},
+		{"a/**", "a/x/y", true},
+	} {
+		if got := MatchGlob(c.pattern, c.path); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %v", c.pattern, c.path, got)
+		}
+	}
+}
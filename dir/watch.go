@@ -0,0 +1,372 @@
+package dir
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventOp 文件变化类型
+type EventOp int
+
+const (
+	EventCreate EventOp = iota + 1
+	EventWrite          // 内容修改，编辑器先写临时文件再rename覆盖也是EventWrite
+	EventRemove
+	EventRename // 被移走，新路径(如果仍在监视范围内)会收到EventCreate
+	EventChmod
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventWrite:
+		return "write"
+	case EventRemove:
+		return "remove"
+	case EventRename:
+		return "rename"
+	case EventChmod:
+		return "chmod"
+	}
+	return "unknown"
+}
+
+// WatchEvent 合并后的文件变化事件
+type WatchEvent struct {
+	Op      EventOp
+	Path    string // 完整路径
+	RelPath string // 相对监视目录的路径，使用/分隔
+	IsDir   bool
+	Time    time.Time // 合并前第一个事件的时间
+}
+
+// WatchOptions 监视选项，Include和Exclude规则同WalkOptions
+type WatchOptions struct {
+	Recursive     bool          // 监视所有子目录，新建的子目录自动加入
+	Include       []string      // 只报告匹配的文件
+	Exclude       []string      // 忽略匹配的文件和目录，被排除的目录不会监视
+	IncludeDirs   bool          // 是否报告目录的事件
+	Debounce      time.Duration // 同一路径在该时间内没有新事件才报告，默认100ms
+	MaxDelay      time.Duration // 持续有事件时最长延迟，默认Debounce的10倍
+	BufferSize    int           // Events的缓冲大小，默认64
+	KeepTempFiles bool          // 默认忽略编辑器的交换文件、备份文件和AtomicWriter的临时文件
+}
+
+// editorTempPatterns 常见编辑器保存时使用的临时文件
+var editorTempPatterns = []string{
+	"*.swp", "*.swx", "*.swpx", "*~", ".#*", "#*#", "4913",
+	".*.tmp-*", "*.tmp", ".goutputstream-*", "*___jb_tmp___", "*___jb_old___",
+}
+
+// Watcher 目录监视器，Events需要及时读取，否则会阻塞后续事件
+type Watcher struct {
+	Events <-chan WatchEvent
+	Errors <-chan error // 缓冲满时丢弃错误
+
+	root    string
+	file    string // 监视单个文件时的文件名
+	opts    WatchOptions
+	include []ignoreRule
+	exclude []ignoreRule
+
+	fw      *fsnotify.Watcher
+	events  chan WatchEvent
+	errors  chan error
+	known   map[string]bool // 已存在的路径，值表示是否为目录，只在loop中访问
+	pending map[string]*pendingEvent
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type pendingEvent struct {
+	ops         fsnotify.Op
+	first, last time.Time
+}
+
+// Watch 监视目录或文件的变化
+// path为文件时监视其所在目录并只报告该文件，文件被rename替换后仍能继续监视
+func Watch(p string, opts WatchOptions) (*Watcher, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * opts.Debounce
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+	w := &Watcher{
+		root:    filepath.Clean(p),
+		opts:    opts,
+		events:  make(chan WatchEvent, opts.BufferSize),
+		errors:  make(chan error, 16),
+		known:   map[string]bool{},
+		pending: map[string]*pendingEvent{},
+		done:    make(chan struct{}),
+	}
+	if !info.IsDir() {
+		w.root, w.file = filepath.Dir(w.root), filepath.Base(w.root)
+		w.opts.Recursive = false
+	}
+	w.Events, w.Errors = w.events, w.errors
+	for _, p := range opts.Include {
+		w.include = append(w.include, parseIgnoreRule("", p))
+	}
+	for _, p := range opts.Exclude {
+		w.exclude = append(w.exclude, parseIgnoreRule("", p))
+	}
+	if w.fw, err = fsnotify.NewWatcher(); err != nil {
+		return nil, err
+	}
+	if err = w.addTree(w.root, false); err != nil {
+		w.fw.Close()
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+// Close 停止监视并关闭Events和Errors，尚未报告的事件被丢弃
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.fw.Close()
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	})
+	return err
+}
+
+// addTree 监视目录，递归时包括所有子目录；emit为true时目录是新建的，其中已有的文件报告为EventCreate
+func (w *Watcher) addTree(dir string, emit bool) error {
+	if err := w.fw.Add(dir); err != nil {
+		return err
+	}
+	opts := WalkOptions{IncludeDirs: true, Symlinks: SymlinkInclude}
+	if !w.opts.Recursive {
+		opts.MaxDepth = 1
+	}
+	return Walk(dir, opts, func(e WalkEntry) error {
+		isDir := e.Info.IsDir()
+		// Exclude相对根目录匹配，不能交给从dir开始的Walk
+		if rel, ok := w.rel(e.Path); !ok {
+			return nil
+		} else if w.ignored(e.Path, rel) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isDir && w.opts.Recursive {
+			if err := w.fw.Add(e.Path); err != nil {
+				w.sendErr(err)
+			}
+		}
+		if emit {
+			w.record(e.Path, fsnotify.Create)
+		} else {
+			w.known[e.Path] = isDir
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+	interval := w.opts.Debounce / 2
+	if interval < 5*time.Millisecond {
+		interval = 5 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			w.sendErr(err)
+		case now := <-ticker.C:
+			w.flush(now)
+		}
+	}
+}
+
+// rel 相对根目录的路径，根目录本身或不在根目录下时返回false
+func (w *Watcher) rel(p string) (string, bool) {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func (w *Watcher) isDir(p string) bool {
+	if isDir, ok := w.known[p]; ok {
+		return isDir
+	}
+	info, err := os.Lstat(p)
+	return err == nil && info.IsDir()
+}
+
+// ignored 临时文件、被排除的路径和单文件模式下的其他文件
+func (w *Watcher) ignored(p, rel string) bool {
+	if w.file != "" && rel != w.file {
+		return true
+	}
+	if !w.opts.KeepTempFiles {
+		base := path.Base(rel)
+		for _, pattern := range editorTempPatterns {
+			if ok, _ := path.Match(pattern, base); ok {
+				return true
+			}
+		}
+	}
+	return matchAny(w.exclude, rel, w.isDir(p))
+}
+
+func (w *Watcher) handle(ev fsnotify.Event) {
+	p := filepath.Clean(ev.Name)
+	rel, ok := w.rel(p)
+	if !ok || w.ignored(p, rel) {
+		return
+	}
+	if ev.Op&fsnotify.Create != 0 && w.opts.Recursive {
+		if info, err := os.Lstat(p); err == nil && info.IsDir() {
+			if err := w.addTree(p, true); err != nil {
+				w.sendErr(err)
+			}
+		}
+	}
+	w.record(p, ev.Op)
+}
+
+func (w *Watcher) record(p string, op fsnotify.Op) {
+	now := time.Now()
+	pe, ok := w.pending[p]
+	if !ok {
+		pe = &pendingEvent{first: now}
+		w.pending[p] = pe
+	}
+	pe.ops |= op
+	pe.last = now
+}
+
+// flush 报告已经稳定的路径，按第一个事件的时间排序
+func (w *Watcher) flush(now time.Time) {
+	var ready []string
+	for p, pe := range w.pending {
+		if now.Sub(pe.last) >= w.opts.Debounce || now.Sub(pe.first) >= w.opts.MaxDelay {
+			ready = append(ready, p)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		a, b := w.pending[ready[i]], w.pending[ready[j]]
+		if a.first.Equal(b.first) {
+			return ready[i] < ready[j]
+		}
+		return a.first.Before(b.first)
+	})
+	for _, p := range ready {
+		pe := w.pending[p]
+		delete(w.pending, p)
+		if ev, ok := w.resolve(p, pe); ok {
+			select {
+			case w.events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// resolve 根据当前文件状态和之前是否存在决定事件类型，合并编辑器保存时的rename、删除再创建等操作
+func (w *Watcher) resolve(p string, pe *pendingEvent) (WatchEvent, bool) {
+	wasDir, known := w.known[p]
+	info, err := os.Lstat(p)
+	exists := err == nil
+	var op EventOp
+	switch {
+	case exists && !known:
+		op = EventCreate
+	case exists && pe.ops&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0:
+		op = EventWrite
+	case exists:
+		op = EventChmod
+	case known && pe.ops&fsnotify.Rename != 0 && pe.ops&fsnotify.Remove == 0:
+		op = EventRename
+	case known:
+		op = EventRemove
+	default:
+		// 已经消失的临时文件
+		return WatchEvent{}, false
+	}
+	isDir := wasDir
+	if exists {
+		isDir = info.IsDir()
+		w.known[p] = isDir
+	} else {
+		w.forget(p, wasDir)
+	}
+	rel, _ := w.rel(p)
+	if isDir && !w.opts.IncludeDirs {
+		return WatchEvent{}, false
+	}
+	if !isDir && len(w.include) > 0 && !matchAny(w.include, rel, false) {
+		return WatchEvent{}, false
+	}
+	return WatchEvent{Op: op, Path: p, RelPath: rel, IsDir: isDir, Time: pe.first}, true
+}
+
+// forget 路径已不存在，目录被移走时inotify的监视会跟着移走，需要删除
+func (w *Watcher) forget(p string, isDir bool) {
+	delete(w.known, p)
+	if !isDir {
+		return
+	}
+	_ = w.fw.Remove(p)
+	prefix := p + string(filepath.Separator)
+	for k, d := range w.known {
+		if strings.HasPrefix(k, prefix) {
+			if d {
+				_ = w.fw.Remove(k)
+			}
+			delete(w.known, k)
+		}
+	}
+}
+
+func (w *Watcher) sendErr(err error) {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		err = errors.New("dir: watch event queue overflow, some events were lost")
+	}
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
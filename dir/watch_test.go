@@ -0,0 +1,178 @@
+package dir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const watchDebounce = 50 * time.Millisecond
+
+func startWatch(t *testing.T, p string, opts WatchOptions) *Watcher {
+	t.Helper()
+	if opts.Debounce == 0 {
+		opts.Debounce = watchDebounce
+	}
+	w, err := Watch(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// collect 读取事件直到一段时间内没有新事件
+func collect(t *testing.T, w *Watcher) []WatchEvent {
+	t.Helper()
+	var events []WatchEvent
+	for {
+		select {
+		case ev := <-w.Events:
+			events = append(events, ev)
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(6 * watchDebounce):
+			return events
+		}
+	}
+}
+
+func eventStrings(events []WatchEvent) []string {
+	var out []string
+	for _, ev := range events {
+		out = append(out, ev.Op.String()+" "+ev.RelPath)
+	}
+	return out
+}
+
+func expectEvents(t *testing.T, w *Watcher, want ...string) {
+	t.Helper()
+	got := eventStrings(collect(t, w))
+	if len(got) != len(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	w := startWatch(t, root, WatchOptions{Recursive: true})
+
+	// 多次写入合并为一个事件
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectEvents(t, w, "write a.txt")
+
+	// 创建后立即删除的文件不报告
+	tmp := filepath.Join(root, "sub", "gone.txt")
+	os.WriteFile(tmp, nil, 0644)
+	os.Remove(tmp)
+	os.Remove(filepath.Join(root, "sub", "b.txt"))
+	expectEvents(t, w, "remove sub/b.txt")
+
+	// 新建的子目录自动监视，其中已有的文件也会报告
+	makeTree(t, root, map[string]string{"new/deep/c.txt": "c"})
+	expectEvents(t, w, "create new/deep/c.txt")
+	os.WriteFile(filepath.Join(root, "new", "deep", "c.txt"), []byte("cc"), 0644)
+	expectEvents(t, w, "write new/deep/c.txt")
+
+	os.Rename(filepath.Join(root, "a.txt"), filepath.Join(root, "new", "a.txt"))
+	expectEvents(t, w, "rename a.txt", "create new/a.txt")
+}
+
+func TestWatchAtomicSave(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"conf.yaml": "a: 1", "other.txt": "x"})
+	target := filepath.Join(root, "conf.yaml")
+	w := startWatch(t, target, WatchOptions{})
+
+	// 写临时文件后rename覆盖
+	if err := WriteFileAtomic(target, []byte("a: 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, "write conf.yaml")
+
+	// vim: 原文件改名为备份，写新文件，删除备份
+	os.Rename(target, target+"~")
+	os.WriteFile(filepath.Join(root, "4913"), nil, 0644)
+	os.Remove(filepath.Join(root, "4913"))
+	os.WriteFile(target, []byte("a: 3"), 0644)
+	os.Remove(target + "~")
+	os.WriteFile(filepath.Join(root, "other.txt"), []byte("y"), 0644)
+	expectEvents(t, w, "write conf.yaml")
+
+	os.Remove(target)
+	expectEvents(t, w, "remove conf.yaml")
+	os.WriteFile(target, []byte("a: 4"), 0644)
+	expectEvents(t, w, "create conf.yaml")
+}
+
+func TestWatchFilters(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"in/": "", "skip/": "", "top/": ""})
+	w := startWatch(t, root, WatchOptions{
+		Recursive:   true,
+		Include:     []string{"*.csv"},
+		Exclude:     []string{"skip/"},
+		IncludeDirs: true,
+	})
+	makeTree(t, root, map[string]string{
+		"in/a.csv":   "1",
+		"in/a.txt":   "2",
+		"skip/b.csv": "3",
+		"in/.a.swp":  "4",
+	})
+	expectEvents(t, w, "create in/a.csv")
+
+	os.Mkdir(filepath.Join(root, "dir2"), 0755)
+	expectEvents(t, w, "create dir2")
+
+	// 不递归时只报告第一层
+	w2 := startWatch(t, root, WatchOptions{})
+	makeTree(t, root, map[string]string{"top/x.txt": "x", "y.txt": "y"})
+	expectEvents(t, w2, "create y.txt")
+}
+
+func TestWatchClose(t *testing.T) {
+	w, err := Watch(t.TempDir(), WatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-w.Events; ok {
+		t.Fatal("Events not closed")
+	}
+	if _, err := Watch(filepath.Join(t.TempDir(), "missing"), WatchOptions{}); !os.IsNotExist(err) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWatchMovedInDir(t *testing.T) {
+	root := t.TempDir()
+	w := startWatch(t, root, WatchOptions{Recursive: true, Exclude: []string{"a/skip"}})
+	// 移入的目录中已有的文件同样经过过滤，Exclude相对监视的根目录
+	src := filepath.Join(t.TempDir(), "a")
+	makeTree(t, src, map[string]string{"keep.txt": "1", "x.swp": "2", "skip/f": "3"})
+	if err := os.Rename(src, filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, "create a/keep.txt")
+
+	// 以..开头的文件名不是上级目录
+	os.WriteFile(filepath.Join(root, "..data"), []byte("x"), 0644)
+	expectEvents(t, w, "create ..data")
+}
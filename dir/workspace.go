@@ -0,0 +1,410 @@
+package dir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("dir: workspace quota exceeded")
+
+// workspaceLockSuffix 工作区旁边的锁文件，工作区存在期间持有共享锁，清理时据此判断所属进程是否已经退出
+const workspaceLockSuffix = ".lock"
+
+// WorkspaceOptions 临时工作区选项
+type WorkspaceOptions struct {
+	Dir   string // 工作区的上级目录，默认os.TempDir()
+	Quota int64  // 通过Workspace写入的总字节数上限，0不限制；直接通过Path写入的文件不计入，可用Usage检查
+	// CleanupOnSignal 收到SIGINT或SIGTERM时清理所有工作区，然后按原信号退出
+	// 程序自己处理信号时不要设置，应在退出前调用CleanupWorkspaces
+	CleanupOnSignal bool
+}
+
+// Workspace 临时工作区，记录通过它创建的所有文件和目录，Close时整个删除
+type Workspace struct {
+	root  string
+	quota int64
+	lock  *FileLock
+
+	mu      sync.Mutex
+	used    int64
+	sizes   map[string]int64 // 已创建的路径对应的字节数，目录以/结尾
+	created []string         // 按创建顺序的相对路径，目录以/结尾
+	closed  bool
+}
+
+var (
+	workspaceMu   sync.Mutex
+	workspaces    = map[*Workspace]struct{}{}
+	workspaceOnce sync.Once
+)
+
+// TempWorkspace 在系统临时目录中创建名称以prefix开头的工作区
+func TempWorkspace(prefix string) (*Workspace, error) {
+	return TempWorkspaceWith(prefix, WorkspaceOptions{})
+}
+
+// TempWorkspaceWith 按opts创建工作区
+func TempWorkspaceWith(prefix string, opts WorkspaceOptions) (*Workspace, error) {
+	parent := opts.Dir
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	root, err := os.MkdirTemp(parent, prefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	lock, err := Lock(root+workspaceLockSuffix, LockShared, 0)
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	w := &Workspace{root: root, quota: opts.Quota, lock: lock, sizes: map[string]int64{}}
+	workspaceMu.Lock()
+	workspaces[w] = struct{}{}
+	workspaceMu.Unlock()
+	if opts.CleanupOnSignal {
+		workspaceOnce.Do(cleanupOnSignal)
+	}
+	return w, nil
+}
+
+// CleanupWorkspaces 关闭当前进程中所有未关闭的工作区，程序退出前调用
+func CleanupWorkspaces() error {
+	workspaceMu.Lock()
+	list := make([]*Workspace, 0, len(workspaces))
+	for w := range workspaces {
+		list = append(list, w)
+	}
+	workspaceMu.Unlock()
+	var first error
+	for _, w := range list {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func cleanupOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		CleanupWorkspaces()
+		// 恢复默认处理后重新发送信号，不支持时直接退出
+		signal.Stop(c)
+		if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+			os.Exit(1)
+		}
+	}()
+}
+
+// Path 工作区的根目录
+func (w *Workspace) Path() string {
+	return w.root
+}
+
+// Join 工作区中的路径，rel使用/分隔
+func (w *Workspace) Join(rel string) string {
+	return filepath.Join(w.root, filepath.FromSlash(rel))
+}
+
+// path 检查工作区未关闭且rel不会越过工作区
+func (w *Workspace) path(rel string) (string, string, error) {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return "", "", &fs.PathError{Op: "workspace", Path: rel, Err: os.ErrClosed}
+	}
+	clean := path.Clean(strings.ReplaceAll(rel, `\`, "/"))
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(rel) != "" {
+		return "", "", &fs.PathError{Op: "workspace", Path: rel, Err: ErrUnsafePath}
+	}
+	return filepath.Join(w.root, filepath.FromSlash(clean)), clean, nil
+}
+
+// track 记录新创建的路径及其上级目录，调用时需要持有mu
+func (w *Workspace) track(rel string, isDir bool) {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/") + "/"
+		if _, ok := w.sizes[dir]; !ok {
+			w.sizes[dir] = 0
+			w.created = append(w.created, dir)
+		}
+	}
+	if isDir {
+		rel += "/"
+	}
+	if _, ok := w.sizes[rel]; !ok {
+		w.sizes[rel] = 0
+		w.created = append(w.created, rel)
+	}
+}
+
+// reserve 占用n字节的配额，n可以为负数
+func (w *Workspace) reserve(n int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if n > 0 && w.quota > 0 && w.used+n > w.quota {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, w.used, w.quota)
+	}
+	w.used += n
+	return nil
+}
+
+// Mkdir 创建目录及其上级目录
+func (w *Workspace) Mkdir(rel string) error {
+	p, clean, err := w.path(rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p, os.ModePerm); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.track(clean, true)
+	w.mu.Unlock()
+	return nil
+}
+
+// Create 创建文件，写入时检查配额，超过配额时返回ErrQuotaExceeded且不写入
+func (w *Workspace) Create(rel string) (io.WriteCloser, error) {
+	p, clean, err := w.path(rel)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.track(clean, false)
+	// 覆盖已有文件时释放原来占用的配额
+	w.used -= w.sizes[clean]
+	w.sizes[clean] = 0
+	w.mu.Unlock()
+	return &workspaceFile{f: f, w: w, rel: clean}, nil
+}
+
+type workspaceFile struct {
+	f   *os.File
+	w   *Workspace
+	rel string
+}
+
+func (f *workspaceFile) Write(p []byte) (int, error) {
+	if err := f.w.reserve(int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := f.f.Write(p)
+	f.w.mu.Lock()
+	f.w.used -= int64(len(p) - n)
+	f.w.sizes[f.rel] += int64(n)
+	f.w.mu.Unlock()
+	return n, err
+}
+
+func (f *workspaceFile) Close() error {
+	return f.f.Close()
+}
+
+// WriteFile 写入文件，自动创建上级目录
+func (w *Workspace) WriteFile(rel string, data []byte) error {
+	f, err := w.Create(rel)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// WriteFiles 按路径顺序创建多个文件，路径以/结尾时创建目录
+func (w *Workspace) WriteFiles(files map[string]string) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = w.Mkdir(name)
+		} else {
+			err = w.WriteFile(name, []byte(files[name]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyFixture 把fixture目录中的内容复制到工作区的dst下，dst为"."时复制到根目录，保留文件权限
+func (w *Workspace) CopyFixture(fixture, dst string) error {
+	return Walk(fixture, WalkOptions{IncludeDirs: true}, func(e WalkEntry) error {
+		rel := path.Join(dst, e.RelPath)
+		if e.Info.IsDir() {
+			return w.Mkdir(rel)
+		}
+		src, err := os.Open(e.Path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		f, err := w.Create(rel)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, src)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Chmod(w.Join(rel), e.Info.Mode().Perm())
+	})
+}
+
+// Created 通过工作区创建的路径，按创建顺序，目录以/结尾
+func (w *Workspace) Created() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.created...)
+}
+
+// Used 通过工作区写入的字节数
+func (w *Workspace) Used() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.used
+}
+
+// Usage 工作区中所有文件实际占用的字节数，包括直接通过Path写入的文件
+func (w *Workspace) Usage() (int64, error) {
+	var total int64
+	err := Walk(w.root, WalkOptions{}, func(e WalkEntry) error {
+		total += e.Info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// Close 删除整个工作区，可以多次调用
+func (w *Workspace) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	workspaceMu.Lock()
+	delete(workspaces, w)
+	workspaceMu.Unlock()
+
+	err := os.RemoveAll(w.root)
+	// 持有锁时删除锁文件，否则其他进程可能在两步之间对它加锁
+	if rerr := removeLocked(w.root+workspaceLockSuffix, w.lock); err == nil && !os.IsNotExist(rerr) {
+		err = rerr
+	}
+	return err
+}
+
+// CleanStaleWorkspaces 删除parent中名称以prefix开头、创建超过ttl且所属进程已经退出的工作区，返回删除的路径
+// parent为空时使用os.TempDir()，只处理TempWorkspace创建的带锁文件的目录
+func CleanStaleWorkspaces(parent, prefix string, ttl time.Duration) ([]string, error) {
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	var first error
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		root := filepath.Join(parent, name)
+		ok, err := cleanStale(root, ttl)
+		if err != nil && first == nil {
+			first = err
+		}
+		if ok {
+			removed = append(removed, root)
+		}
+	}
+	return removed, first
+}
+
+func cleanStale(root string, ttl time.Duration) (bool, error) {
+	lockPath := root + workspaceLockSuffix
+	info, err := os.Stat(lockPath)
+	if err != nil || time.Since(info.ModTime()) < ttl {
+		return false, nil
+	}
+	// 所属进程退出后共享锁自动释放，能加排他锁说明没有进程在使用
+	lock, err := Lock(lockPath, LockExclusive, 0)
+	if errors.Is(err, ErrLocked) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = os.RemoveAll(root); err != nil {
+		lock.Unlock()
+		return false, err
+	}
+	return true, removeLocked(lockPath, lock)
+}
+
+// StartWorkspaceJanitor 每interval清理一次过期的工作区，直到ctx结束或调用返回的stop，清理出错时调用onError(可以为nil)
+// stop等待后台goroutine退出，返回后不会再调用onError
+func StartWorkspaceJanitor(ctx context.Context, parent, prefix string, ttl, interval time.Duration, onError func(error)) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ctx.Err() == nil {
+			if _, err := CleanStaleWorkspaces(parent, prefix, ttl); err != nil && onError != nil {
+				onError(err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,168 @@
+package dir
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWorkspace(t *testing.T) {
+	w, err := TempWorkspaceWith("ws-test-", WorkspaceOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := w.Path()
+	if err := w.WriteFiles(map[string]string{
+		"a.txt":       "aaa",
+		"sub/b/c.txt": "cc",
+		"empty/":      "",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.txt", "empty/", "sub/", "sub/b/", "sub/b/c.txt"}
+	if got := w.Created(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("created = %v, want %v", got, want)
+	}
+	if s, _ := ReadString(w.Join("sub/b/c.txt")); s != "cc" {
+		t.Fatalf("c.txt = %q", s)
+	}
+	os.WriteFile(filepath.Join(root, "direct.txt"), []byte("12345"), 0644)
+	if usage, err := w.Usage(); err != nil || usage != 10 || w.Used() != 5 {
+		t.Fatalf("usage = %d, used = %d, err = %v", usage, w.Used(), err)
+	}
+	for _, bad := range []string{"../x", "/etc/x", "a/../../x", "."} {
+		if err := w.WriteFile(bad, nil); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: err = %v", bad, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(root) || IsExist(root+workspaceLockSuffix) {
+		t.Fatal("workspace not removed")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile("x", nil); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWorkspaceQuota(t *testing.T) {
+	w, err := TempWorkspaceWith("ws-quota-", WorkspaceOptions{Dir: t.TempDir(), Quota: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.WriteFile("a", make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile("b", make([]byte, 5)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	// 覆盖文件释放原来的配额
+	if err := w.WriteFile("a", make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile("b", make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if w.Used() != 7 {
+		t.Fatalf("used = %d", w.Used())
+	}
+
+	fixture := t.TempDir()
+	makeTree(t, fixture, map[string]string{"x/big.bin": "0123456789"})
+	if err := w.CopyFixture(fixture, "data"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWorkspaceFixture(t *testing.T) {
+	fixture := t.TempDir()
+	makeTree(t, fixture, map[string]string{"conf/app.yaml": "a: 1", "run.sh": "#!/bin/sh"})
+	os.Chmod(filepath.Join(fixture, "run.sh"), 0755)
+	w, err := TempWorkspaceWith("ws-fixture-", WorkspaceOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.CopyFixture(fixture, "."); err != nil {
+		t.Fatal(err)
+	}
+	got := walkRel(t, w.Path(), WalkOptions{IncludeDirs: true})
+	if want := walkRel(t, fixture, WalkOptions{IncludeDirs: true}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if info, _ := os.Stat(w.Join("run.sh")); info.Mode().Perm() != 0755 && os.PathSeparator == '/' {
+		t.Fatalf("mode = %v", info.Mode())
+	}
+}
+
+func TestCleanupWorkspaces(t *testing.T) {
+	parent := t.TempDir()
+	w1, _ := TempWorkspaceWith("ws-a-", WorkspaceOptions{Dir: parent})
+	w2, _ := TempWorkspaceWith("ws-b-", WorkspaceOptions{Dir: parent})
+	if err := CleanupWorkspaces(); err != nil {
+		t.Fatal(err)
+	}
+	if IsExist(w1.Path()) || IsExist(w2.Path()) {
+		t.Fatal("workspaces not removed")
+	}
+}
+
+func TestCleanStaleWorkspaces(t *testing.T) {
+	parent := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	// 模拟崩溃的进程留下的工作区：锁文件存在但没有被持有
+	stale := filepath.Join(parent, "job-stale")
+	makeTree(t, stale, map[string]string{"f": "x"})
+	os.WriteFile(stale+workspaceLockSuffix, nil, 0644)
+	os.Chtimes(stale+workspaceLockSuffix, old, old)
+	young := filepath.Join(parent, "job-young")
+	os.Mkdir(young, 0755)
+	os.WriteFile(young+workspaceLockSuffix, nil, 0644)
+	foreign := filepath.Join(parent, "job-foreign")
+	os.Mkdir(foreign, 0755)
+	os.Chtimes(foreign, old, old)
+	live, err := TempWorkspaceWith("job-", WorkspaceOptions{Dir: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	os.Chtimes(live.Path()+workspaceLockSuffix, old, old)
+
+	removed, err := CleanStaleWorkspaces(parent, "job-", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{stale}) {
+		t.Fatalf("removed = %v", removed)
+	}
+	for _, p := range []string{young, foreign, live.Path()} {
+		if !IsExist(p) {
+			t.Fatalf("%s removed", p)
+		}
+	}
+	if IsExist(stale) || IsExist(stale+workspaceLockSuffix) {
+		t.Fatal("stale workspace not removed")
+	}
+
+	// 后台定期清理
+	os.Chtimes(young+workspaceLockSuffix, old, old)
+	stop := StartWorkspaceJanitor(context.Background(), parent, "job-", time.Hour, 10*time.Millisecond, func(err error) { t.Error(err) })
+	defer stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for IsExist(young) {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not remove stale workspace")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
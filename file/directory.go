@@ -3,6 +3,8 @@ package file
 import (
 	"fmt"
 	"os"
+
+	"github.com/Mark-lupp/go-lib/dir"
 )
 
 //@function: PathExists
@@ -10,7 +12,15 @@ import (
 //@param: path string "文件目录路径"
 //@return: bool, error "存在true不存在false，调用失败错误"
 func PathExists(path string) (bool, error) {
-	_, err := os.Stat(path)
+	return PathExistsFS(dir.OS, path)
+}
+
+//@function: PathExistsFS
+//@description: 指定文件系统中文件目录是否存在
+//@param: fsys dir.FS "文件系统", path string "文件目录路径"
+//@return: bool, error "存在true不存在false，调用失败错误"
+func PathExistsFS(fsys dir.FS, path string) (bool, error) {
+	_, err := fsys.Stat(path)
 	if err == nil {
 		return true, nil
 	}
@@ -25,13 +35,21 @@ func PathExists(path string) (bool, error) {
 //@param: dirs ...string "文件夹路径数组"
 //@return: err error "调用失败错误"
 func CreateDir(dirs ...string) (err error) {
+	return CreateDirFS(dir.OS, dirs...)
+}
+
+//@function: CreateDirFS
+//@description: 在指定文件系统中批量创建文件夹
+//@param: fsys dir.FS "文件系统", dirs ...string "文件夹路径数组"
+//@return: err error "调用失败错误"
+func CreateDirFS(fsys dir.FS, dirs ...string) (err error) {
 	for _, v := range dirs {
-		exist, err := PathExists(v)
+		exist, err := PathExistsFS(fsys, v)
 		if err != nil {
 			return err
 		}
 		if !exist {
-			err = os.MkdirAll(v, os.ModePerm)
+			err = fsys.MkdirAll(v, os.ModePerm)
 			if err != nil {
 				return fmt.Errorf("create directory: %v Fail,error: %v", v, err)
 			}
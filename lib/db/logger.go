@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	stdlog "log"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/log"
+	"go.mongodb.org/mongo-driver/event"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"xorm.io/core"
+)
+
+// 第三方库日志使用的子logger名称，可以通过log.Logger.SetNamedLevel单独调整级别
+const (
+	XormLoggerName  = "xorm"
+	RedisLoggerName = "redis"
+	MongoLoggerName = "mongo"
+)
+
+// XormLogger 实现xorm的core.ILogger，SQL和xorm内部日志写入log包的文件
+// SetLevel对应子logger xorm的级别
+type XormLogger struct {
+	lg      *log.Logger
+	sugar   *zap.SugaredLogger
+	showSQL bool
+}
+
+func NewXormLogger(lg *log.Logger) *XormLogger {
+	return &XormLogger{lg: lg, sugar: lg.Named(XormLoggerName).WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (x *XormLogger) Debug(v ...interface{})                 { x.sugar.Debug(v...) }
+func (x *XormLogger) Debugf(format string, v ...interface{}) { x.sugar.Debugf(format, v...) }
+func (x *XormLogger) Info(v ...interface{})                  { x.sugar.Info(v...) }
+func (x *XormLogger) Infof(format string, v ...interface{})  { x.sugar.Infof(format, v...) }
+func (x *XormLogger) Warn(v ...interface{})                  { x.sugar.Warn(v...) }
+func (x *XormLogger) Warnf(format string, v ...interface{})  { x.sugar.Warnf(format, v...) }
+func (x *XormLogger) Error(v ...interface{})                 { x.sugar.Error(v...) }
+func (x *XormLogger) Errorf(format string, v ...interface{}) { x.sugar.Errorf(format, v...) }
+
+func (x *XormLogger) Level() core.LogLevel {
+	lvl := x.lg.Level()
+	if s, ok := x.lg.Levels().Named[XormLoggerName]; ok {
+		_ = lvl.UnmarshalText([]byte(s))
+	}
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return core.LOG_DEBUG
+	case lvl == zapcore.InfoLevel:
+		return core.LOG_INFO
+	case lvl == zapcore.WarnLevel:
+		return core.LOG_WARNING
+	default:
+		return core.LOG_ERR
+	}
+}
+
+func (x *XormLogger) SetLevel(l core.LogLevel) {
+	switch l {
+	case core.LOG_DEBUG:
+		x.lg.SetNamedLevel(XormLoggerName, zapcore.DebugLevel)
+	case core.LOG_INFO:
+		x.lg.SetNamedLevel(XormLoggerName, zapcore.InfoLevel)
+	case core.LOG_WARNING:
+		x.lg.SetNamedLevel(XormLoggerName, zapcore.WarnLevel)
+	case core.LOG_ERR:
+		x.lg.SetNamedLevel(XormLoggerName, zapcore.ErrorLevel)
+	default:
+		// LOG_OFF只保留fatal
+		x.lg.SetNamedLevel(XormLoggerName, zapcore.FatalLevel)
+	}
+}
+
+func (x *XormLogger) ShowSQL(show ...bool) {
+	x.showSQL = len(show) == 0 || show[0]
+}
+
+func (x *XormLogger) IsShowSQL() bool {
+	return x.showSQL
+}
+
+// NewRedisLogger go-redis内部日志(连接池、哨兵切换等)，通过redis.SetLogger设置
+func NewRedisLogger(lg *log.Logger) *stdlog.Logger {
+	return stdlog.New(log.NewLevelWriter(lg.Named(RedisLoggerName), zapcore.WarnLevel), "", 0)
+}
+
+// NewMongoMonitor mongo命令监控，失败的命令输出error，耗时超过slow的输出warn
+// slow<=0时所有成功的命令都输出debug
+func NewMongoMonitor(lg *log.Logger, slow time.Duration) *event.CommandMonitor {
+	mlg := lg.Named(MongoLoggerName)
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			d := time.Duration(e.DurationNanos)
+			fields := []zap.Field{zap.String("command", e.CommandName), zap.Int64("request_id", e.RequestID),
+				zap.String("connection", e.ConnectionID), zap.Duration("duration", d)}
+			switch {
+			case slow <= 0:
+				mlg.Debug("[mongo] command succeeded", fields...)
+			case d >= slow:
+				mlg.Warn("[mongo] slow command", fields...)
+			}
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mlg.Error("[mongo] command failed", zap.String("command", e.CommandName), zap.Int64("request_id", e.RequestID),
+				zap.String("connection", e.ConnectionID), zap.Duration("duration", time.Duration(e.DurationNanos)),
+				zap.String("failure", e.Failure))
+		},
+	}
+}
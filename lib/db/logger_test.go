@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/log"
+	"go.mongodb.org/mongo-driver/event"
+	"xorm.io/core"
+)
+
+func TestThirdPartyLoggers(t *testing.T) {
+	dir := t.TempDir()
+	lg := log.NewLogger(log.SetLogFileDir(dir), log.SetAppName("db"), log.SetSingleFile("all.log"))
+
+	x := NewXormLogger(lg)
+	x.SetLevel(core.LOG_WARNING)
+	if x.Level() != core.LOG_WARNING {
+		t.Fatalf("xorm level = %v", x.Level())
+	}
+	x.Infof("[SQL] %s", "SELECT 1")
+	x.Warnf("slow sql %d", 3)
+
+	NewRedisLogger(lg).Printf("redis: discarding bad conn")
+
+	m := NewMongoMonitor(lg, 100*time.Millisecond)
+	m.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", DurationNanos: int64(time.Millisecond)}})
+	m.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "aggregate", DurationNanos: int64(time.Second)}})
+	m.Failed(context.Background(), &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert"}, Failure: "duplicate key"})
+	_ = lg.Close()
+
+	bs, _ := os.ReadFile(filepath.Join(dir, "db-all.log"))
+	out := string(bs)
+	for _, want := range []string{`"logger":"xorm","caller":"db/logger_test.go`, `"msg":"slow sql 3"`, `"logger":"redis"`, `"msg":"redis: discarding bad conn"`,
+		`"msg":"[mongo] slow command","command":"aggregate"`, `"failure":"duplicate key"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s", want)
+		}
+	}
+	for _, unwanted := range []string{"SELECT 1", `"command":"find"`} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output should not contain %s", unwanted)
+		}
+	}
+}
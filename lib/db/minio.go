@@ -14,5 +14,5 @@ func initMinio() {
 	if minioClient, err = minio.New(config.GetMinioConfig().GetPath(), config.GetMinioConfig().GetAccessKeyId(), config.GetMinioConfig().GetSecretAccessKey(), secure); err != nil {
 		panic(err)
 	}
-	log.NewLogger().Debug("minio success : " + config.GetMinioConfig().GetPath())
+	log.Default().Debug("minio success : " + config.GetMinioConfig().GetPath())
 }
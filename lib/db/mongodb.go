@@ -22,9 +22,10 @@ func initMongoDb() {
 	}
 	opt.SetMaxConnIdleTime(5 * time.Second) //指定连接可以保持空闲的最大毫秒数
 	opt.SetMaxPoolSize(200)                 //使用最大的连接数
+	opt.SetMonitor(NewMongoMonitor(log.Default(), time.Second))
 	if mgo, err = mongo.Connect(context.TODO(), opt); err != nil {
 		panic(err)
 	}
-	log.NewLogger().Debug("mgo success : " + config.GetMgoConfig().GetUrl())
+	log.Default().Debug("mgo success : " + config.GetMgoConfig().GetUrl())
 
 }
@@ -14,14 +14,15 @@ import (
 //mysql连接池
 func initMysql() {
 	var err error
-	sql := fmt.Sprintf("%s:%s@(%s:%d)/%s", config.GetMysqlConfig().GetUser(), config.GetMysqlConfig().GetPwd(),
-		config.GetMysqlConfig().GetIp(), config.GetMysqlConfig().GetPort(), config.GetMysqlConfig().GetDbName())
-	log.NewLogger().Debug("[initMysql] " + sql)
+	sql := mysqlDSN(config.GetMysqlConfig().GetPwd())
+	// 日志中不输出密码
+	log.Default().Debug("[initMysql] " + mysqlDSN("******"))
 	mysqlEngine, err = xorm.NewEngine("mysql", sql)
 	if err != nil {
-		log.NewLogger().Error("[initMysql] "+sql, zap.Error(err))
+		log.Default().Error("[initMysql] "+mysqlDSN("******"), zap.Error(err))
 		os.Exit(0)
 	}
+	mysqlEngine.SetLogger(NewXormLogger(log.Default()))
 	mysqlEngine.SetMaxOpenConns(config.GetMysqlConfig().GetPoolSize())
 	mysqlEngine.SetMaxIdleConns(config.GetMysqlConfig().GetPoolSize())
 	if err = mysqlEngine.Ping(); err != nil {
@@ -29,6 +30,11 @@ func initMysql() {
 	}
 }
 
+func mysqlDSN(pwd string) string {
+	return fmt.Sprintf("%s:%s@(%s:%d)/%s", config.GetMysqlConfig().GetUser(), pwd,
+		config.GetMysqlConfig().GetIp(), config.GetMysqlConfig().GetPort(), config.GetMysqlConfig().GetDbName())
+}
+
 func CloseMysqlConnection() {
 	_ = mysqlEngine.Close()
 }
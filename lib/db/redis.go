@@ -11,7 +11,7 @@ import (
 )
 
 func initRedis() {
-
+	redis.SetLogger(NewRedisLogger(log.Default()))
 	redisDb = redis.NewClient(
 		&redis.Options{
 			Addr:         fmt.Sprintf("%s:%s", config.GetRedisConfig().GetIP(), config.GetRedisConfig().GetPort()),
@@ -24,10 +24,10 @@ func initRedis() {
 	)
 	err = redisDb.Ping().Err()
 	if nil != err {
-		log.NewLogger().Error("ping redis err:", zap.Error(err))
+		log.Default().Error("ping redis err:", zap.Error(err))
 		panic(err)
 	}
-	log.NewLogger().Debug("redis success : " + fmt.Sprintf("%s:%s", config.GetRedisConfig().GetIP(), config.GetRedisConfig().GetPort()))
+	log.Default().Debug("redis success : " + fmt.Sprintf("%s:%s", config.GetRedisConfig().GetIP(), config.GetRedisConfig().GetPort()))
 
 }
 
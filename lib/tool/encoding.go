@@ -0,0 +1,125 @@
+package tool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base58Alphabet    = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// 三种字母表都按ASCII升序排列，定长编码后字符串顺序与字节顺序一致
+var (
+	Base32 = newCrockfordEncoding()
+	Base58 = NewEncoding(base58Alphabet)
+	Base62 = NewEncoding(base62Alphabet)
+)
+
+var ErrInvalidEncoding = errors.New("invalid encoded id")
+
+// Encoding 把大端字节串当作无符号大整数做定长进制转换
+// 同样长度的输入编码后长度相同，不同长度的输入编码长度也不同，所以Decode能还原原始字节数
+type Encoding struct {
+	alphabet string
+	base     *big.Int
+	bits     float64 // 每个字符承载的位数 log2(base)
+	index    [256]int16
+}
+
+func NewEncoding(alphabet string) *Encoding {
+	e := &Encoding{
+		alphabet: alphabet,
+		base:     big.NewInt(int64(len(alphabet))),
+		bits:     math.Log2(float64(len(alphabet))),
+	}
+	for i := range e.index {
+		e.index[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		e.index[alphabet[i]] = int16(i)
+	}
+	return e
+}
+
+// newCrockfordEncoding Crockford base32，解码时忽略大小写并把I/L当作1、O当作0
+func newCrockfordEncoding() *Encoding {
+	e := NewEncoding(crockfordAlphabet)
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		c := crockfordAlphabet[i]
+		if c >= 'A' && c <= 'Z' {
+			e.index[c+'a'-'A'] = e.index[c]
+		}
+	}
+	for _, c := range "Ii" + "Ll" {
+		e.index[c] = 1
+	}
+	e.index['O'], e.index['o'] = 0, 0
+	return e
+}
+
+// EncodedLen n个字节编码后的长度
+func (e *Encoding) EncodedLen(n int) int {
+	return int(math.Ceil(float64(n*8) / e.bits))
+}
+
+func (e *Encoding) Encode(src []byte) string {
+	width := e.EncodedLen(len(src))
+	dst := make([]byte, width)
+	n := new(big.Int).SetBytes(src)
+	mod := new(big.Int)
+	for i := width - 1; i >= 0; i-- {
+		n.QuoRem(n, e.base, mod)
+		dst[i] = e.alphabet[mod.Int64()]
+	}
+	return string(dst)
+}
+
+func (e *Encoding) Decode(s string) ([]byte, error) {
+	size := -1
+	for n := 0; e.EncodedLen(n) <= len(s); n++ {
+		if e.EncodedLen(n) == len(s) {
+			size = n
+			break
+		}
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w: bad length %d", ErrInvalidEncoding, len(s))
+	}
+	n := new(big.Int)
+	for i := 0; i < len(s); i++ {
+		v := e.index[s[i]]
+		if v < 0 {
+			return nil, fmt.Errorf("%w: bad character %q at %d", ErrInvalidEncoding, s[i], i)
+		}
+		n.Mul(n, e.base)
+		n.Add(n, big.NewInt(int64(v)))
+	}
+	if n.BitLen() > size*8 {
+		return nil, fmt.Errorf("%w: value overflows %d bytes", ErrInvalidEncoding, size)
+	}
+	return n.FillBytes(make([]byte, size)), nil
+}
+
+// EncodeInt64 用于snowflake等int64 ID，负数按补码处理
+func (e *Encoding) EncodeInt64(id int64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(id))
+	return e.Encode(b[:])
+}
+
+func (e *Encoding) DecodeInt64(s string) (int64, error) {
+	b, err := e.Decode(s)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 8 {
+		return 0, fmt.Errorf("%w: not an int64", ErrInvalidEncoding)
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
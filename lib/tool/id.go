@@ -0,0 +1,230 @@
+package tool
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// IDGenerator 统一的ID生成接口
+// NextBytes 返回大端字节序的ID，按字节比较即按生成时间排序
+// NextString 返回各方案的标准字符串格式
+type IDGenerator interface {
+	NextBytes() ([]byte, error)
+	NextString() (string, error)
+}
+
+var (
+	_ IDGenerator = (*Snowflake)(nil)
+	_ IDGenerator = (*ULIDGenerator)(nil)
+	_ IDGenerator = (*UUIDv7Generator)(nil)
+	_ IDGenerator = (*EncodedGenerator)(nil)
+)
+
+var ErrULIDOverflow = errors.New("ulid: monotonic entropy overflow")
+
+// EncodedGenerator 使用指定编码输出字符串，对外暴露的ID更短且不直接暴露机器位
+type EncodedGenerator struct {
+	IDGenerator
+	enc *Encoding
+}
+
+func NewEncodedGenerator(gen IDGenerator, enc *Encoding) *EncodedGenerator {
+	return &EncodedGenerator{IDGenerator: gen, enc: enc}
+}
+
+func (g *EncodedGenerator) NextString() (string, error) {
+	b, err := g.NextBytes()
+	if err != nil {
+		return "", err
+	}
+	return g.enc.Encode(b), nil
+}
+
+// ULID 48位毫秒时间戳 + 80位随机数
+type ULID [16]byte
+
+func (u ULID) String() string {
+	return Base32.Encode(u[:])
+}
+
+func (u ULID) Time() time.Time {
+	ms := int64(binary.BigEndian.Uint64(append([]byte{0, 0}, u[:6]...)))
+	return time.Unix(ms/1e3, (ms%1e3)*1e6)
+}
+
+func ParseULID(s string) (u ULID, err error) {
+	if len(s) != 26 {
+		return u, fmt.Errorf("%w: ulid must be 26 characters", ErrInvalidEncoding)
+	}
+	b, err := Base32.Decode(s)
+	if err != nil {
+		return u, err
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+// ULIDGenerator 同一毫秒内随机部分递增，保证单调
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+	lastMs  uint64
+	last    ULID
+
+	now func() time.Time
+}
+
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{entropy: rand.Reader, now: time.Now}
+}
+
+func (g *ULIDGenerator) Next() (ULID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixNano() / 1e6)
+	if ms <= g.lastMs {
+		// 同一毫秒或时钟回拨：沿用上一个时间戳，随机部分+1
+		u := g.last
+		for i := 15; i >= 6; i-- {
+			u[i]++
+			if u[i] != 0 {
+				g.last = u
+				return u, nil
+			}
+		}
+		return ULID{}, ErrULIDOverflow
+	}
+	var u ULID
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(u[:6], ts[2:])
+	if _, err := io.ReadFull(g.entropy, u[6:]); err != nil {
+		return ULID{}, err
+	}
+	g.lastMs, g.last = ms, u
+	return u, nil
+}
+
+func (g *ULIDGenerator) NextBytes() ([]byte, error) {
+	u, err := g.Next()
+	if err != nil {
+		return nil, err
+	}
+	return u[:], nil
+}
+
+func (g *ULIDGenerator) NextString() (string, error) {
+	u, err := g.Next()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// UUID RFC 9562 格式的UUID
+type UUID [16]byte
+
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time UUIDv7中的毫秒时间戳
+func (u UUID) Time() time.Time {
+	ms := int64(binary.BigEndian.Uint64(append([]byte{0, 0}, u[:6]...)))
+	return time.Unix(ms/1e3, (ms%1e3)*1e6)
+}
+
+func ParseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("%w: bad uuid format", ErrInvalidEncoding)
+	}
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err = hex.Decode(u[:], []byte(h)); err != nil {
+		return u, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return u, nil
+}
+
+// UUIDv7Generator 48位毫秒时间戳 + 12位毫秒内计数器(rand_a) + 62位随机数
+// 计数器用完时时间戳+1，保证同一进程内单调递增
+type UUIDv7Generator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+	lastMs  uint64
+	counter uint16
+
+	now func() time.Time
+}
+
+func NewUUIDv7Generator() *UUIDv7Generator {
+	return &UUIDv7Generator{entropy: rand.Reader, now: time.Now}
+}
+
+func (g *UUIDv7Generator) Next() (UUID, error) {
+	var u UUID
+	if _, err := io.ReadFull(g.entropy, u[8:]); err != nil {
+		return u, err
+	}
+
+	g.mu.Lock()
+	ms := uint64(g.now().UnixNano() / 1e6)
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		g.counter++
+		if g.counter > 0xfff {
+			ms++
+			g.counter = 0
+		}
+	} else {
+		// 新的毫秒从随机的低位开始，留出一半空间给递增
+		g.counter = uint16(u[8])<<3&0x7ff | uint16(u[9]&0x7)
+	}
+	g.lastMs = ms
+	counter := g.counter
+	g.mu.Unlock()
+
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(u[:6], ts[2:])
+	u[6] = 0x70 | byte(counter>>8)
+	u[7] = byte(counter)
+	u[8] = u[8]&0x3f | 0x80
+	return u, nil
+}
+
+func (g *UUIDv7Generator) NextBytes() ([]byte, error) {
+	u, err := g.Next()
+	if err != nil {
+		return nil, err
+	}
+	return u[:], nil
+}
+
+func (g *UUIDv7Generator) NextString() (string, error) {
+	u, err := g.Next()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0},
+		{0, 0, 1},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		bytes.Repeat([]byte{0x5a}, 16),
+	}
+	for name, enc := range map[string]*Encoding{"base32": Base32, "base58": Base58, "base62": Base62} {
+		for _, in := range inputs {
+			s := enc.Encode(in)
+			if len(s) != enc.EncodedLen(len(in)) {
+				t.Fatalf("%s: len(%q) = %d", name, s, len(s))
+			}
+			out, err := enc.Decode(s)
+			if err != nil || !bytes.Equal(in, out) {
+				t.Fatalf("%s: Decode(%q) = %x, %v; want %x", name, s, out, err, in)
+			}
+		}
+		if _, err := enc.Decode("!!"); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if _, err := Base62.Decode(strings.Repeat("z", 11)); err == nil {
+		t.Fatal("expected overflow error")
+	}
+	id, err := Base62.DecodeInt64(Base62.EncodeInt64(1234567890123))
+	if err != nil || id != 1234567890123 {
+		t.Fatalf("DecodeInt64 = %d, %v", id, err)
+	}
+}
+
+func TestCrockfordAliases(t *testing.T) {
+	a, _ := Base32.Decode("0O1IL")
+	b, _ := Base32.Decode("00111")
+	if !bytes.Equal(a, b) {
+		t.Fatalf("%x != %x", a, b)
+	}
+}
+
+func TestIDGeneratorsSortable(t *testing.T) {
+	sf, err := NewSnowflake(SetWorkerId(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gens := map[string]IDGenerator{
+		"snowflake":   sf,
+		"ulid":        NewULIDGenerator(),
+		"uuidv7":      NewUUIDv7Generator(),
+		"ulid-base58": NewEncodedGenerator(NewULIDGenerator(), Base58),
+		"sf-base62":   NewEncodedGenerator(sf, Base62),
+	}
+	for name, gen := range gens {
+		ids := make([]string, 1000)
+		for i := range ids {
+			if ids[i], err = gen.NextString(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if name != "snowflake" && !sort.StringsAreSorted(ids) {
+			t.Fatalf("%s: ids not sorted", name)
+		}
+		seen := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("%s: duplicate id %s", name, id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestULIDAndUUIDParse(t *testing.T) {
+	u, err := NewULIDGenerator().Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseULID(strings.ToLower(u.String()))
+	if err != nil || p != u {
+		t.Fatalf("ParseULID = %v, %v", p, err)
+	}
+
+	v, err := NewUUIDv7Generator().Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version() != 7 || v[8]>>6 != 2 {
+		t.Fatalf("bad version/variant: %s", v)
+	}
+	q, err := ParseUUID(v.String())
+	if err != nil || q != v {
+		t.Fatalf("ParseUUID = %v, %v", q, err)
+	}
+}
@@ -0,0 +1,216 @@
+package tool
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	defaultDriftFactor = 0.01
+	defaultRetryCount  = 3
+	defaultRetryDelay  = 200 * time.Millisecond
+)
+
+var (
+	ErrRedLockNoClients = errors.New("redlock: no redis clients")
+	ErrRedLockFailed    = errors.New("redlock: failed to acquire lock on quorum")
+)
+
+// 只有value一致时才删除/续期，避免释放掉别人持有的锁
+var (
+	unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+else
+	return 0
+end`)
+	extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+else
+	return 0
+end`)
+)
+
+// RedLock 基于多个相互独立的redis节点的quorum锁(Redlock算法)
+// 单节点的RedisLock在主从切换时可能把同一把锁发给两个客户端
+type RedLock struct {
+	conns       []*redis.Client
+	timeout     time.Duration
+	key         string
+	val         string
+	driftFactor float64
+	retryCount  int
+	retryDelay  time.Duration
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+type RedLockOption func(lock *RedLock)
+
+// SetRedLockDriftFactor 设置时钟漂移系数，默认0.01
+func SetRedLockDriftFactor(factor float64) RedLockOption {
+	return func(lock *RedLock) {
+		lock.driftFactor = factor
+	}
+}
+
+// SetRedLockRetry 设置Lock的重试次数和重试间隔，默认3次/200ms
+func SetRedLockRetry(count int, delay time.Duration) RedLockOption {
+	return func(lock *RedLock) {
+		lock.retryCount = count
+		lock.retryDelay = delay
+	}
+}
+
+func NewRedLock(conns []*redis.Client, key, val string, timeout time.Duration, opts ...RedLockOption) *RedLock {
+	lock := &RedLock{
+		conns:       conns,
+		timeout:     timeout,
+		key:         key,
+		val:         val,
+		driftFactor: defaultDriftFactor,
+		retryCount:  defaultRetryCount,
+		retryDelay:  defaultRetryDelay,
+	}
+	for _, fn := range opts {
+		fn(lock)
+	}
+	return lock
+}
+
+func (lock *RedLock) quorum() int {
+	return len(lock.conns)/2 + 1
+}
+
+// drift 时钟漂移 = timeout * driftFactor + 2ms (redis过期精度)
+func (lock *RedLock) drift() time.Duration {
+	return time.Duration(float64(lock.timeout)*lock.driftFactor) + 2*time.Millisecond
+}
+
+// TryLock 尝试一次加锁
+// return true ===> 在多数节点上加锁成功，且锁剩余有效期大于0
+func (lock *RedLock) TryLock() (bool, error) {
+	if len(lock.conns) == 0 {
+		return false, ErrRedLockNoClients
+	}
+	start := time.Now()
+	n := lock.each(func(conn *redis.Client) (bool, error) {
+		return conn.SetNX(lock.key, lock.val, lock.timeout).Result()
+	})
+	validity := lock.timeout - time.Since(start) - lock.drift()
+	if n >= lock.quorum() && validity > 0 {
+		lock.mu.Lock()
+		lock.until = start.Add(lock.timeout - lock.drift())
+		lock.mu.Unlock()
+		return true, nil
+	}
+	// 未达到quorum，释放已经拿到的节点
+	_ = lock.UnLock()
+	return false, nil
+}
+
+// Lock 按重试策略加锁，重试次数用完仍失败时返回ErrRedLockFailed
+func (lock *RedLock) Lock() error {
+	for i := 0; i <= lock.retryCount; i++ {
+		if i > 0 && lock.retryDelay > 0 {
+			// 随机化重试间隔，避免多个客户端同时重试导致都拿不到quorum
+			time.Sleep(lock.retryDelay/2 + time.Duration(rand.Int63n(int64(lock.retryDelay))))
+		}
+		ok, err := lock.TryLock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ErrRedLockFailed
+}
+
+// Extend 在多数节点上延长锁的过期时间
+func (lock *RedLock) Extend() (bool, error) {
+	if len(lock.conns) == 0 {
+		return false, ErrRedLockNoClients
+	}
+	start := time.Now()
+	n := lock.each(func(conn *redis.Client) (bool, error) {
+		res, err := extendScript.Run(conn, []string{lock.key}, lock.val, int64(lock.timeout/time.Millisecond)).Int64()
+		return res == 1, err
+	})
+	validity := lock.timeout - time.Since(start) - lock.drift()
+	if n >= lock.quorum() && validity > 0 {
+		lock.mu.Lock()
+		lock.until = start.Add(lock.timeout - lock.drift())
+		lock.mu.Unlock()
+		return true, nil
+	}
+	return false, nil
+}
+
+// UnLock 在所有节点上释放锁(包括加锁失败的节点，防止SET已执行但响应丢失)
+func (lock *RedLock) UnLock() error {
+	var (
+		mu      sync.Mutex
+		lastErr error
+	)
+	lock.each(func(conn *redis.Client) (bool, error) {
+		err := unlockScript.Run(conn, []string{lock.key}, lock.val).Err()
+		if err != nil && err != redis.Nil {
+			mu.Lock()
+			lastErr = err
+			mu.Unlock()
+		}
+		return err == nil, nil
+	})
+	lock.mu.Lock()
+	lock.until = time.Time{}
+	lock.mu.Unlock()
+	return lastErr
+}
+
+// Validity 锁的剩余有效期，未持有锁时返回0
+func (lock *RedLock) Validity() time.Duration {
+	lock.mu.Lock()
+	defer lock.mu.Unlock()
+	if d := time.Until(lock.until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (lock *RedLock) GetLockKey() string {
+	return lock.key
+}
+
+func (lock *RedLock) GetLockVal() string {
+	return lock.val
+}
+
+// each 并发在所有节点上执行fn，返回成功的节点数
+func (lock *RedLock) each(fn func(conn *redis.Client) (bool, error)) int {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		n  int
+	)
+	for _, conn := range lock.conns {
+		wg.Add(1)
+		go func(conn *redis.Client) {
+			defer wg.Done()
+			ok, err := fn(conn)
+			if err == nil && ok {
+				mu.Lock()
+				n++
+				mu.Unlock()
+			}
+		}(conn)
+	}
+	wg.Wait()
+	return n
+}
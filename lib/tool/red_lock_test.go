@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func newRedLockNodes(t *testing.T, n int) ([]*miniredis.Miniredis, []*redis.Client) {
+	servers := make([]*miniredis.Miniredis, 0, n)
+	conns := make([]*redis.Client, 0, n)
+	for i := 0; i < n; i++ {
+		s, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Close)
+		conn := redis.NewClient(&redis.Options{Addr: s.Addr(), MaxRetries: 0})
+		t.Cleanup(func() { _ = conn.Close() })
+		servers = append(servers, s)
+		conns = append(conns, conn)
+	}
+	return servers, conns
+}
+
+func TestRedLock(t *testing.T) {
+	servers, conns := newRedLockNodes(t, 3)
+
+	a := NewRedLock(conns, "lock:order", "a", time.Second)
+	ok, err := a.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("first TryLock = %v, %v", ok, err)
+	}
+	if a.Validity() <= 0 {
+		t.Fatal("validity should be positive after lock")
+	}
+
+	b := NewRedLock(conns, "lock:order", "b", time.Second, SetRedLockRetry(0, 0))
+	if ok, _ = b.TryLock(); ok {
+		t.Fatal("second client must not get the lock")
+	}
+
+	// 释放只删除自己的值
+	if err = b.UnLock(); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range servers {
+		if v, _ := s.Get("lock:order"); v != "a" {
+			t.Fatalf("node %d value = %q", i, v)
+		}
+	}
+
+	if err = a.UnLock(); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range servers {
+		if s.Exists("lock:order") {
+			t.Fatalf("node %d still holds the lock", i)
+		}
+	}
+	if err = b.Lock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedLockQuorum(t *testing.T) {
+	servers, conns := newRedLockNodes(t, 3)
+
+	// 一个节点宕机，仍然满足多数
+	servers[0].Close()
+	lock := NewRedLock(conns, "lock:q", "v", time.Second)
+	if ok, err := lock.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock with 2/3 nodes = %v, %v", ok, err)
+	}
+	_ = lock.UnLock()
+
+	// 另一个节点已被别人占用，只剩1/3，加锁失败并回滚
+	_ = servers[1].Set("lock:q", "other")
+	if ok, _ := lock.TryLock(); ok {
+		t.Fatal("TryLock must fail without quorum")
+	}
+	if servers[2].Exists("lock:q") {
+		t.Fatal("partial lock must be released")
+	}
+	if lock.Validity() != 0 {
+		t.Fatal("validity should be zero when not locked")
+	}
+}
+
+func TestRedLockExtend(t *testing.T) {
+	servers, conns := newRedLockNodes(t, 3)
+
+	lock := NewRedLock(conns, "lock:e", "v", time.Second)
+	if ok, err := lock.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	for _, s := range servers {
+		s.FastForward(800 * time.Millisecond)
+	}
+	if ok, err := lock.Extend(); err != nil || !ok {
+		t.Fatalf("Extend = %v, %v", ok, err)
+	}
+	for i, s := range servers {
+		if ttl := s.TTL("lock:e"); ttl != time.Second {
+			t.Fatalf("node %d ttl = %v", i, ttl)
+		}
+	}
+}
+
+func TestRedLockNoClients(t *testing.T) {
+	if _, err := NewRedLock(nil, "k", "v", time.Second).TryLock(); err != ErrRedLockNoClients {
+		t.Fatalf("err = %v", err)
+	}
+}
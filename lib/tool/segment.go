@@ -0,0 +1,218 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/lib/db"
+	"github.com/go-xorm/xorm"
+)
+
+const defaultPrefetchRatio = 0.9
+
+var ErrSegmentTagNotFound = errors.New("segment: biz tag not found")
+
+// IdSegment 号段表，每个业务一行
+// max_id为已分配出去的最大值，每次取号段执行 max_id = max_id + step
+type IdSegment struct {
+	BizTag      string    `xorm:"varchar(128) pk notnull 'biz_tag'"`
+	MaxId       int64     `xorm:"bigint notnull 'max_id'"`
+	Step        int64     `xorm:"bigint notnull 'step'"`
+	Description string    `xorm:"varchar(256) 'description'"`
+	UpdateTime  time.Time `xorm:"updated 'update_time'"`
+}
+
+func (IdSegment) TableName() string {
+	return "id_segment"
+}
+
+// SegmentAllocator 号段模式(Leaf-segment)的ID分配器
+// 每个业务tag持有两个号段(双buffer)，当前号段剩余不足 step*prefetchRatio 时异步加载下一个号段
+// 生成的ID在同一tag内单调递增；进程重启会丢弃未用完的号段，所以ID只会跳跃不会重复
+type SegmentAllocator struct {
+	engine        *xorm.Engine
+	prefetchRatio float64
+
+	mu      sync.RWMutex
+	buffers map[string]*segmentBuffer
+}
+
+type SegmentOption func(a *SegmentAllocator)
+
+// SetSegmentPrefetchRatio 当前号段剩余比例低于ratio时预取下一个号段，默认0.9
+func SetSegmentPrefetchRatio(ratio float64) SegmentOption {
+	return func(a *SegmentAllocator) {
+		a.prefetchRatio = ratio
+	}
+}
+
+type segment struct {
+	value int64 // 下一个可用的ID
+	max   int64 // 不包含
+	step  int64
+}
+
+func (s *segment) remaining() int64 {
+	return s.max - s.value
+}
+
+type segmentBuffer struct {
+	tag      string
+	inited   chan struct{} // 第一个号段加载完成后关闭
+	initErr  error
+	mu       sync.Mutex
+	cond     *sync.Cond
+	segments [2]*segment
+	cur      int
+	ready    bool // segments[1-cur] 已加载好
+	loading  bool
+	err      error // 上次预取失败的错误，当前号段用完前不再预取
+}
+
+func NewSegmentAllocator(engine *xorm.Engine, opts ...SegmentOption) *SegmentAllocator {
+	a := &SegmentAllocator{
+		engine:        engine,
+		prefetchRatio: defaultPrefetchRatio,
+		buffers:       make(map[string]*segmentBuffer),
+	}
+	for _, fn := range opts {
+		fn(a)
+	}
+	return a
+}
+
+// NewSegmentAllocatorWithDb 使用lib/db初始化好的mysql连接
+func NewSegmentAllocatorWithDb(opts ...SegmentOption) *SegmentAllocator {
+	return NewSegmentAllocator(db.GetMysqlDb(), opts...)
+}
+
+// SyncTable 创建或更新号段表结构
+func (a *SegmentAllocator) SyncTable() error {
+	return a.engine.Sync2(new(IdSegment))
+}
+
+// CreateTag 新增业务tag，第一个ID为start
+func (a *SegmentAllocator) CreateTag(tag string, start, step int64, description string) error {
+	if step <= 0 {
+		return fmt.Errorf("segment: step must be positive, got %d", step)
+	}
+	_, err := a.engine.Insert(&IdSegment{
+		BizTag:      tag,
+		MaxId:       start,
+		Step:        step,
+		Description: description,
+	})
+	return err
+}
+
+// NextId 取tag的下一个ID
+func (a *SegmentAllocator) NextId(tag string) (int64, error) {
+	buf, err := a.buffer(tag)
+	if err != nil {
+		return 0, err
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	for {
+		seg := buf.segments[buf.cur]
+		if !buf.ready && !buf.loading && buf.err == nil && float64(seg.remaining()) < float64(seg.step)*a.prefetchRatio {
+			buf.loading = true
+			go a.prefetch(buf)
+		}
+		if seg.value < seg.max {
+			id := seg.value
+			seg.value++
+			return id, nil
+		}
+		switch {
+		case buf.ready:
+			buf.cur = 1 - buf.cur
+			buf.ready = false
+		case buf.loading:
+			buf.cond.Wait()
+		default:
+			// 异步加载失败，同步重试一次，仍失败时返回错误，下次调用重新预取
+			buf.err = nil
+			next, err := a.loadSegment(tag)
+			if err != nil {
+				return 0, err
+			}
+			buf.segments[buf.cur] = next
+		}
+	}
+}
+
+// buffer 取tag的双buffer，第一次使用时加载第一个号段
+// 加载在全局锁之外进行，不阻塞其他tag；同一tag的并发调用等待同一次加载，失败时下次调用重新加载
+func (a *SegmentAllocator) buffer(tag string) (*segmentBuffer, error) {
+	a.mu.RLock()
+	buf, ok := a.buffers[tag]
+	a.mu.RUnlock()
+	if !ok {
+		a.mu.Lock()
+		if buf, ok = a.buffers[tag]; !ok {
+			buf = &segmentBuffer{tag: tag, inited: make(chan struct{})}
+			buf.cond = sync.NewCond(&buf.mu)
+			a.buffers[tag] = buf
+		}
+		a.mu.Unlock()
+		if !ok {
+			buf.segments[0], buf.initErr = a.loadSegment(tag)
+			if buf.initErr != nil {
+				a.mu.Lock()
+				delete(a.buffers, tag)
+				a.mu.Unlock()
+			}
+			close(buf.inited)
+		}
+	}
+	<-buf.inited
+	if buf.initErr != nil {
+		return nil, buf.initErr
+	}
+	return buf, nil
+}
+
+func (a *SegmentAllocator) prefetch(buf *segmentBuffer) {
+	seg, err := a.loadSegment(buf.tag)
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.loading = false
+	// 失败时ready仍为false，当前号段用完后NextId会同步重试
+	buf.err = err
+	if err == nil {
+		buf.segments[1-buf.cur] = seg
+		buf.ready = true
+	}
+	buf.cond.Broadcast()
+}
+
+// loadSegment 在事务中推进max_id并读取新号段 [max_id-step, max_id)
+func (a *SegmentAllocator) loadSegment(tag string) (*segment, error) {
+	session := a.engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return nil, err
+	}
+	res, err := session.Exec("UPDATE "+IdSegment{}.TableName()+" SET max_id = max_id + step, update_time = ? WHERE biz_tag = ?", time.Now(), tag)
+	if err != nil {
+		_ = session.Rollback()
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = session.Rollback()
+		return nil, fmt.Errorf("%w: %s", ErrSegmentTagNotFound, tag)
+	}
+	row := &IdSegment{}
+	if _, err = session.Where("biz_tag = ?", tag).Get(row); err != nil {
+		_ = session.Rollback()
+		return nil, err
+	}
+	if err = session.Commit(); err != nil {
+		return nil, err
+	}
+	return &segment{value: row.MaxId - row.Step, max: row.MaxId, step: row.Step}, nil
+}
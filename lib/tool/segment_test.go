@@ -0,0 +1,154 @@
+package tool
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-xorm/xorm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newSegmentEngine(t *testing.T) *xorm.Engine {
+	engine, err := xorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "segment.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+	return engine
+}
+
+func TestSegmentAllocator(t *testing.T) {
+	engine := newSegmentEngine(t)
+	a := NewSegmentAllocator(engine)
+	if err := a.SyncTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateTag("order", 1000, 10, "order no"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateTag("user", 1, 3, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for want := int64(1000); want < 1050; want++ {
+		id, err := a.NextId("order")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("order id = %d, want %d", id, want)
+		}
+	}
+	for want := int64(1); want < 10; want++ {
+		if id, _ := a.NextId("user"); id != want {
+			t.Fatalf("user id = %d, want %d", id, want)
+		}
+	}
+	if _, err := a.NextId("missing"); !errors.Is(err, ErrSegmentTagNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	// 加载失败的tag不会被缓存，创建后可以正常取号
+	if err := a.CreateTag("missing", 7, 5, ""); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := a.NextId("missing"); err != nil || id != 7 {
+		t.Fatalf("missing id = %d, %v", id, err)
+	}
+
+	// 重启后从数据库中的max_id继续，不会与之前的ID重复
+	row := &IdSegment{}
+	if _, err := engine.Where("biz_tag = ?", "order").Get(row); err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewSegmentAllocator(engine).NextId("order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != row.MaxId {
+		t.Fatalf("id after restart = %d, want %d", id, row.MaxId)
+	}
+}
+
+func TestSegmentAllocatorConcurrent(t *testing.T) {
+	engine := newSegmentEngine(t)
+	a := NewSegmentAllocator(engine, SetSegmentPrefetchRatio(0.5))
+	if err := a.SyncTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateTag("order", 1, 50, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, each = 8, 200
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int64]bool, workers*each)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for j := 0; j < each; j++ {
+				id, err := a.NextId("order")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if id <= last {
+					t.Errorf("id %d not increasing after %d", id, last)
+				}
+				last = id
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*each {
+		t.Fatalf("got %d ids", len(seen))
+	}
+}
+
+func TestSegmentAllocatorDbDown(t *testing.T) {
+	engine := newSegmentEngine(t)
+	a := NewSegmentAllocator(engine, SetSegmentPrefetchRatio(0.5))
+	if err := a.SyncTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateTag("order", 1, 10, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.NextId("order"); err != nil {
+		t.Fatal(err)
+	}
+	_ = engine.Close()
+
+	// 预取失败后用完当前号段，NextId返回错误而不是一直重试
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 20; i++ {
+			if _, err := a.NextId("order"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error after segment exhausted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NextId hangs while db is down")
+	}
+}
@@ -1,23 +1,242 @@
 package tool
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/Mark-lupp/go-lib/lib/config"
 )
 
 const (
-	workerBits  uint8 = 10
-	numberBits  uint8 = 12
-	workerMax   int64 = -1 ^ (-1 << workerBits)
-	numberMax   int64 = -1 ^ (-1 << numberBits)
-	timeShift   uint8 = workerBits + numberBits
-	workerShift uint8 = numberBits
-	startTime   int64 = 1563172432000 // 如果在程序跑了一段时间修改了epoch这个值 可能会导致生成相同的ID //毫秒
+	workerBits uint8 = 10
+	numberBits uint8 = 12
+	workerMax  int64 = -1 ^ (-1 << workerBits)
+	startTime  int64 = 1563172432000 // 如果在程序跑了一段时间修改了epoch这个值 可能会导致生成相同的ID //毫秒
+
+	minTimeBits        uint8 = 31 // 时间戳至少保留31位(毫秒约24天)，否则很快溢出
+	defaultMaxRollback       = 10 * time.Millisecond
 )
 
+var (
+	ErrClockMovedBackwards = errors.New("snowflake: clock moved backwards")
+	ErrTimeOverflow        = errors.New("snowflake: timestamp overflow, check epoch and bit widths")
+)
+
+// Snowflake ID生成器
+// 布局(高位到低位): 1位符号 | 时间戳(毫秒, 相对epoch) | 数据中心 | 机器 | 序列号
+// 默认布局与旧的Worker一致: 0位数据中心 + 10位机器 + 12位序列号, epoch=startTime
+type Snowflake struct {
+	mu sync.Mutex
+
+	epoch       int64 // 毫秒
+	dcBits      uint8
+	workerBits  uint8
+	seqBits     uint8
+	dcId        int64
+	workerId    int64
+	maxRollback time.Duration
+
+	timestamp int64
+	sequence  int64
+
+	check func() error
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+type SnowflakeOption func(s *Snowflake)
+
+// SnowflakeId Parse的结果
+type SnowflakeId struct {
+	Id           int64
+	Time         time.Time
+	DatacenterId int64
+	WorkerId     int64
+	Sequence     int64
+}
+
+// SetEpoch 设置起始时间，服务上线后不可修改，否则可能生成重复ID
+func SetEpoch(epoch time.Time) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.epoch = epoch.UnixNano() / 1e6
+	}
+}
+
+// SetBits 设置数据中心、机器、序列号的位数，剩余位数用于时间戳
+func SetBits(dcBits, workerBits, seqBits uint8) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.dcBits = dcBits
+		s.workerBits = workerBits
+		s.seqBits = seqBits
+	}
+}
+
+// SetDatacenterId 设置数据中心编号
+func SetDatacenterId(dcId int64) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.dcId = dcId
+	}
+}
+
+// SetWorkerId 设置机器编号
+func SetWorkerId(workerId int64) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.workerId = workerId
+	}
+}
+
+// SetMaxClockRollback 时钟回拨不超过d时等待追上，超过则NextId返回ErrClockMovedBackwards，默认10ms
+func SetMaxClockRollback(d time.Duration) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.maxRollback = d
+	}
+}
+
+// SetLeaseCheck 每次NextId前调用check, 返回错误时不再发号(例如机器编号租约丢失)
+func SetLeaseCheck(check func() error) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.check = check
+	}
+}
+
+func NewSnowflake(opts ...SnowflakeOption) (*Snowflake, error) {
+	s := &Snowflake{
+		epoch:       startTime,
+		dcBits:      0,
+		workerBits:  workerBits,
+		seqBits:     numberBits,
+		maxRollback: defaultMaxRollback,
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+	for _, fn := range opts {
+		fn(s)
+	}
+	if s.seqBits == 0 {
+		return nil, errors.New("snowflake: sequence bits must be positive")
+	}
+	if int(s.dcBits)+int(s.workerBits)+int(s.seqBits) > int(63-minTimeBits) {
+		return nil, fmt.Errorf("snowflake: datacenter+worker+sequence bits must not exceed %d", 63-minTimeBits)
+	}
+	if s.dcId < 0 || s.dcId > maxOf(s.dcBits) {
+		return nil, fmt.Errorf("snowflake: datacenter id %d out of range [0, %d]", s.dcId, maxOf(s.dcBits))
+	}
+	if s.workerId < 0 || s.workerId > maxOf(s.workerBits) {
+		return nil, fmt.Errorf("snowflake: worker id %d out of range [0, %d]", s.workerId, maxOf(s.workerBits))
+	}
+	if s.epoch > s.millis() {
+		return nil, errors.New("snowflake: epoch is in the future")
+	}
+	return s, nil
+}
+
+// NewSnowflakeWithConfig 使用tool.ini [zap] dc 作为数据中心编号
+// 布局为5位数据中心 + 5位机器 + 12位序列号，可通过opts覆盖
+func NewSnowflakeWithConfig(workerId int64, opts ...SnowflakeOption) (*Snowflake, error) {
+	opts = append([]SnowflakeOption{
+		SetBits(5, 5, numberBits),
+		SetDatacenterId(config.GetToolLogConfig().GetDcId()),
+		SetWorkerId(workerId),
+	}, opts...)
+	return NewSnowflake(opts...)
+}
+
+// NextId 生成下一个ID
+// 同一毫秒内序列号用完时休眠到下一毫秒；时钟回拨超过MaxClockRollback时返回ErrClockMovedBackwards
+func (s *Snowflake) NextId() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.check != nil {
+		if err := s.check(); err != nil {
+			return 0, err
+		}
+	}
+	now := s.millis()
+	if now < s.timestamp {
+		back := time.Duration(s.timestamp-now) * time.Millisecond
+		if back > s.maxRollback {
+			return 0, fmt.Errorf("%w: %v", ErrClockMovedBackwards, back)
+		}
+		s.sleep(back)
+		if now = s.millis(); now < s.timestamp {
+			return 0, fmt.Errorf("%w: %v", ErrClockMovedBackwards, time.Duration(s.timestamp-now)*time.Millisecond)
+		}
+	}
+	if now == s.timestamp {
+		s.sequence = (s.sequence + 1) & maxOf(s.seqBits)
+		if s.sequence == 0 {
+			for now <= s.timestamp {
+				s.sleep(time.Duration(s.timestamp-now+1) * time.Millisecond)
+				now = s.millis()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.timestamp = now
+
+	elapsed := now - s.epoch
+	if elapsed > maxOf(63-s.dcBits-s.workerBits-s.seqBits) {
+		return 0, ErrTimeOverflow
+	}
+	workerShift := s.seqBits
+	dcShift := workerShift + s.workerBits
+	timeShift := dcShift + s.dcBits
+	return elapsed<<timeShift | s.dcId<<dcShift | s.workerId<<workerShift | s.sequence, nil
+}
+
+// NextBytes 8字节大端序的ID，实现IDGenerator
+func (s *Snowflake) NextBytes() ([]byte, error) {
+	id, err := s.NextId()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b, nil
+}
+
+// NextString 十进制字符串，实现IDGenerator
+func (s *Snowflake) NextString() (string, error) {
+	id, err := s.NextId()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Parse 按当前生成器的布局解析ID
+func (s *Snowflake) Parse(id int64) SnowflakeId {
+	workerShift := s.seqBits
+	dcShift := workerShift + s.workerBits
+	timeShift := dcShift + s.dcBits
+	ms := id>>timeShift + s.epoch
+	return SnowflakeId{
+		Id:           id,
+		Time:         time.Unix(ms/1e3, (ms%1e3)*1e6),
+		DatacenterId: id >> dcShift & maxOf(s.dcBits),
+		WorkerId:     id >> workerShift & maxOf(s.workerBits),
+		Sequence:     id & maxOf(s.seqBits),
+	}
+}
+
+func (s *Snowflake) millis() int64 {
+	return s.now().UnixNano() / 1e6
+}
+
+func maxOf(bits uint8) int64 {
+	return -1 ^ (-1 << bits)
+}
+
 var wk *Worker
 
+// Deprecated: 使用NewSnowflake创建实例
 func GetWorker() *Worker {
 	if wk == nil {
 		panic("GetWorker not init")
@@ -26,41 +245,32 @@ func GetWorker() *Worker {
 	}
 }
 
+// Worker 旧的全局生成器，内部使用默认布局的Snowflake
+// Deprecated: 使用Snowflake
 type Worker struct {
-	mu        sync.Mutex
-	timestamp int64
-	workerId  int64
-	number    int64
+	sf *Snowflake
 }
 
-func NewWorker(workerId int64) (error) {
+// Deprecated: 使用NewSnowflake(SetWorkerId(workerId))
+func NewWorker(workerId int64) error {
 	if workerId < 0 || workerId > workerMax {
 		return errors.New("Worker ID excess of quantity")
 	}
-	wk = &Worker{
-		timestamp: 0,
-		workerId:  workerId,
-		number:    0,
+	sf, err := NewSnowflake(SetWorkerId(workerId))
+	if err != nil {
+		return err
 	}
 	// 生成一个新节点
+	wk = &Worker{sf: sf}
 	return nil
 }
 
+// GetId 生成ID，时钟回拨超出阈值时返回0
+// Deprecated: 使用Snowflake.NextId
 func (w *Worker) GetId() int64 {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	now := time.Now().UnixNano() / 1e6
-	if w.timestamp == now {
-		w.number++
-		if w.number > numberMax {
-			for now <= w.timestamp {
-				now = time.Now().UnixNano() / 1e6
-			}
-		}
-	} else {
-		w.number = 0
-		w.timestamp = now
+	id, err := w.sf.NextId()
+	if err != nil {
+		return 0
 	}
-	ID := int64((now-startTime)<<timeShift | (w.workerId << workerShift) | (w.number))
-	return ID
+	return id
 }
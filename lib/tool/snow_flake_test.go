@@ -0,0 +1,105 @@
+package tool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestSnowflake(t *testing.T, opts ...SnowflakeOption) (*Snowflake, *fakeClock) {
+	s, err := NewSnowflake(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s.now, s.sleep = clock.now, clock.sleep
+	return s, clock
+}
+
+func TestSnowflakeParse(t *testing.T) {
+	s, clock := newTestSnowflake(t, SetBits(5, 5, 12), SetDatacenterId(3), SetWorkerId(17))
+	id, err := s.NextId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := s.Parse(id)
+	if !p.Time.Equal(clock.t) || p.DatacenterId != 3 || p.WorkerId != 17 || p.Sequence != 0 {
+		t.Fatalf("Parse(%d) = %+v", id, p)
+	}
+	id2, _ := s.NextId()
+	if id2 <= id || s.Parse(id2).Sequence != 1 {
+		t.Fatalf("second id %d not increasing", id2)
+	}
+}
+
+func TestSnowflakeSequenceOverflow(t *testing.T) {
+	s, clock := newTestSnowflake(t, SetBits(0, 10, 2))
+	start := clock.t
+	var last int64
+	for i := 0; i < 5; i++ {
+		id, err := s.NextId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("id %d not increasing", id)
+		}
+		last = id
+	}
+	// 2位序列号每毫秒只能生成4个，第5个应等到下一毫秒
+	if got := clock.t.Sub(start); got != time.Millisecond {
+		t.Fatalf("waited %v", got)
+	}
+}
+
+func TestSnowflakeClockRollback(t *testing.T) {
+	s, clock := newTestSnowflake(t, SetMaxClockRollback(5*time.Millisecond))
+	if _, err := s.NextId(); err != nil {
+		t.Fatal(err)
+	}
+	clock.t = clock.t.Add(-3 * time.Millisecond)
+	if _, err := s.NextId(); err != nil {
+		t.Fatalf("small rollback should wait: %v", err)
+	}
+	clock.t = clock.t.Add(-time.Second)
+	if _, err := s.NextId(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestNewSnowflakeValidate(t *testing.T) {
+	cases := []SnowflakeOption{
+		SetWorkerId(1024),
+		SetDatacenterId(1),
+		SetBits(10, 10, 13),
+		SetBits(0, 10, 0),
+		SetEpoch(time.Now().Add(time.Hour)),
+	}
+	for i, opt := range cases {
+		if _, err := NewSnowflake(opt); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestWorkerCompatible(t *testing.T) {
+	if err := NewWorker(5); err != nil {
+		t.Fatal(err)
+	}
+	id := GetWorker().GetId()
+	if id <= 0 || id>>numberBits&workerMax != 5 {
+		t.Fatalf("GetId = %d", id)
+	}
+}
@@ -0,0 +1,286 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/lib/db"
+	"github.com/go-redis/redis"
+)
+
+const (
+	defaultLeasePrefix = "snowflake:worker:"
+	defaultLeaseTTL    = 30 * time.Second
+)
+
+var (
+	ErrNoFreeWorkerId   = errors.New("worker lease: no free worker id")
+	ErrWorkerLeaseLost  = errors.New("worker lease: lease lost")
+	ErrWorkerNotAcquire = errors.New("worker lease: not acquired")
+)
+
+// WorkerIdAllocator 通过redis租约自动分配snowflake机器编号
+// 每个编号对应一个key: prefix+id, 值为本进程的唯一标识, 后台按TTL/3续期
+// 续期失败(key被删除、被他人占用或超过有效期未续上)即认为租约丢失, 之后Err返回ErrWorkerLeaseLost
+// 有效期从发出请求时算起，扣除时钟漂移(同RedLock)，保证本地认为有效时redis中的key一定还在
+type WorkerIdAllocator struct {
+	conn   *redis.Client
+	prefix string
+	maxId  int64
+	ttl    time.Duration
+	val    string
+	onLost func(id int64)
+
+	opMu      sync.Mutex // 串行化Acquire和Release
+	mu        sync.Mutex
+	id        int64
+	acquired  bool
+	lost      bool
+	lastRenew time.Time
+	lostCh    chan struct{}
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+type AllocatorOption func(a *WorkerIdAllocator)
+
+// SetAllocatorPrefix 设置租约key前缀，默认 snowflake:worker:
+func SetAllocatorPrefix(prefix string) AllocatorOption {
+	return func(a *WorkerIdAllocator) {
+		a.prefix = prefix
+	}
+}
+
+// SetAllocatorTTL 设置租约时长，默认30s，每TTL/3续期一次
+func SetAllocatorTTL(ttl time.Duration) AllocatorOption {
+	return func(a *WorkerIdAllocator) {
+		a.ttl = ttl
+	}
+}
+
+// SetAllocatorMaxId 设置可分配的最大编号，默认1023
+func SetAllocatorMaxId(maxId int64) AllocatorOption {
+	return func(a *WorkerIdAllocator) {
+		a.maxId = maxId
+	}
+}
+
+// SetAllocatorOnLost 租约丢失时的回调
+func SetAllocatorOnLost(fn func(id int64)) AllocatorOption {
+	return func(a *WorkerIdAllocator) {
+		a.onLost = fn
+	}
+}
+
+func NewWorkerIdAllocator(conn *redis.Client, opts ...AllocatorOption) *WorkerIdAllocator {
+	host, _ := os.Hostname()
+	a := &WorkerIdAllocator{
+		conn:   conn,
+		prefix: defaultLeasePrefix,
+		maxId:  workerMax,
+		ttl:    defaultLeaseTTL,
+		val:    host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(rand.Int63(), 36),
+		id:     -1,
+	}
+	for _, fn := range opts {
+		fn(a)
+	}
+	return a
+}
+
+// NewWorkerIdAllocatorWithDb 使用lib/db初始化好的redis连接
+func NewWorkerIdAllocatorWithDb(opts ...AllocatorOption) *WorkerIdAllocator {
+	return NewWorkerIdAllocator(db.GetRedisDb(), opts...)
+}
+
+// Acquire 从随机位置开始依次尝试SETNX, 拿到第一个空闲编号并开始续期
+// 已持有有效租约时直接返回；租约已丢失时编号可能已被其他节点占用，停止旧的续期后重新申请
+func (a *WorkerIdAllocator) Acquire() (int64, error) {
+	a.opMu.Lock()
+	defer a.opMu.Unlock()
+	a.mu.Lock()
+	if a.acquired {
+		a.checkExpired()
+		if !a.lost {
+			id := a.id
+			a.mu.Unlock()
+			return id, nil
+		}
+		a.mu.Unlock()
+		a.stop()
+		a.mu.Lock()
+	}
+	defer a.mu.Unlock()
+	n := a.maxId + 1
+	offset := rand.Int63n(n)
+	for i := int64(0); i < n; i++ {
+		id := (offset + i) % n
+		start := time.Now()
+		ok, err := a.conn.SetNX(a.key(id), a.val, a.ttl).Result()
+		if err != nil {
+			return -1, err
+		}
+		if !ok {
+			continue
+		}
+		a.id = id
+		a.acquired = true
+		a.lost = false
+		a.lastRenew = start
+		a.lostCh = make(chan struct{})
+		a.stopCh = make(chan struct{})
+		a.doneCh = make(chan struct{})
+		go a.heartbeat(a.stopCh, a.doneCh)
+		return id, nil
+	}
+	return -1, ErrNoFreeWorkerId
+}
+
+// Id 当前持有的编号，未持有时返回-1
+func (a *WorkerIdAllocator) Id() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.id
+}
+
+// Err 租约有效时返回nil
+// 即使后台续期还没发现, 超过有效期没有续期成功也视为丢失
+func (a *WorkerIdAllocator) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.acquired {
+		return ErrWorkerNotAcquire
+	}
+	a.checkExpired()
+	if a.lost {
+		return fmt.Errorf("%w: worker id %d", ErrWorkerLeaseLost, a.id)
+	}
+	return nil
+}
+
+// Lost 租约丢失时关闭
+func (a *WorkerIdAllocator) Lost() <-chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lostCh
+}
+
+// NewSnowflake 使用分配到的编号创建Snowflake, 租约丢失后NextId返回错误
+func (a *WorkerIdAllocator) NewSnowflake(opts ...SnowflakeOption) (*Snowflake, error) {
+	id, err := a.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	check := func() error {
+		if err := a.Err(); err != nil {
+			return err
+		}
+		// Release后重新Acquire可能拿到别的编号
+		if a.Id() != id {
+			return fmt.Errorf("%w: worker id %d", ErrWorkerLeaseLost, id)
+		}
+		return nil
+	}
+	opts = append([]SnowflakeOption{SetWorkerId(id), SetLeaseCheck(check)}, opts...)
+	return NewSnowflake(opts...)
+}
+
+// Release 停止续期并释放编号，程序退出时调用
+func (a *WorkerIdAllocator) Release() error {
+	a.opMu.Lock()
+	defer a.opMu.Unlock()
+	a.mu.Lock()
+	acquired := a.acquired
+	a.mu.Unlock()
+	if !acquired {
+		return nil
+	}
+	return a.stop()
+}
+
+// stop 停止续期并删除自己的key，调用方需持有a.opMu，不能持有a.mu
+// acquired=false后Err返回ErrWorkerNotAcquire，关联的Snowflake停止发号
+func (a *WorkerIdAllocator) stop() error {
+	a.mu.Lock()
+	stopCh, doneCh, id := a.stopCh, a.doneCh, a.id
+	a.mu.Unlock()
+
+	close(stopCh)
+	<-doneCh
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acquired = false
+	a.id = -1
+	// 只删除值仍为自己的key，租约丢失后被别人占用的不受影响
+	return unlockScript.Run(a.conn, []string{a.key(id)}, a.val).Err()
+}
+
+func (a *WorkerIdAllocator) heartbeat(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(a.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		a.mu.Lock()
+		id := a.id
+		a.mu.Unlock()
+		// 有效期从发出请求时算起
+		start := time.Now()
+		res, err := extendScript.Run(a.conn, []string{a.key(id)}, a.val, int64(a.ttl/time.Millisecond)).Int64()
+
+		a.mu.Lock()
+		switch {
+		case a.lost:
+		case err == nil && res == 1:
+			a.lastRenew = start
+		case err == nil:
+			// key已过期或被别人占用
+			a.markLost()
+		default:
+			a.checkExpired()
+		}
+		lost := a.lost
+		a.mu.Unlock()
+		if lost {
+			return
+		}
+	}
+}
+
+// validity 租约的本地有效期 = ttl - 时钟漂移
+func (a *WorkerIdAllocator) validity() time.Duration {
+	return a.ttl - time.Duration(float64(a.ttl)*defaultDriftFactor) - 2*time.Millisecond
+}
+
+// checkExpired 超过有效期没有续期成功时标记丢失，调用方需持有a.mu
+func (a *WorkerIdAllocator) checkExpired() {
+	if !a.lost && time.Since(a.lastRenew) >= a.validity() {
+		a.markLost()
+	}
+}
+
+// markLost 调用方需持有a.mu
+func (a *WorkerIdAllocator) markLost() {
+	if a.lost {
+		return
+	}
+	a.lost = true
+	close(a.lostCh)
+	if a.onLost != nil {
+		go a.onLost(a.id)
+	}
+}
+
+func (a *WorkerIdAllocator) key(id int64) string {
+	return a.prefix + strconv.FormatInt(id, 10)
+}
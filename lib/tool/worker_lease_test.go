@@ -0,0 +1,108 @@
+package tool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func newLeaseRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	conn := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { _ = conn.Close() })
+	return s, conn
+}
+
+func TestWorkerIdAllocator(t *testing.T) {
+	s, conn := newLeaseRedis(t)
+
+	a := NewWorkerIdAllocator(conn, SetAllocatorMaxId(1))
+	b := NewWorkerIdAllocator(conn, SetAllocatorMaxId(1))
+	c := NewWorkerIdAllocator(conn, SetAllocatorMaxId(1))
+	idA, err := a.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := b.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idA == idB {
+		t.Fatalf("duplicate worker id %d", idA)
+	}
+	if _, err = c.Acquire(); err != ErrNoFreeWorkerId {
+		t.Fatalf("err = %v", err)
+	}
+
+	if err = a.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists(defaultLeasePrefix+"0") == s.Exists(defaultLeasePrefix+"1") {
+		t.Fatal("released id should be free")
+	}
+	if id, err := c.Acquire(); err != nil || id != idA {
+		t.Fatalf("Acquire after release = %d, %v", id, err)
+	}
+	if err = a.Err(); err != ErrWorkerNotAcquire {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWorkerIdAllocatorLeaseLost(t *testing.T) {
+	s, conn := newLeaseRedis(t)
+
+	lost := make(chan int64, 1)
+	a := NewWorkerIdAllocator(conn, SetAllocatorTTL(150*time.Millisecond), SetAllocatorOnLost(func(id int64) { lost <- id }))
+	sf, err := a.NewSnowflake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Release()
+	if _, err = sf.NextId(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 续期正常时租约一直有效
+	time.Sleep(200 * time.Millisecond)
+	if err = a.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个进程占用了这个编号
+	_ = s.Set(a.key(a.Id()), "other")
+	select {
+	case id := <-lost:
+		if id != a.Id() {
+			t.Fatalf("lost id = %d", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lease loss not detected")
+	}
+	<-a.Lost()
+	if _, err = sf.NextId(); !errors.Is(err, ErrWorkerLeaseLost) {
+		t.Fatalf("NextId after lease lost: %v", err)
+	}
+
+	// 丢失后重新申请，不能继续使用被占用的编号
+	old := a.Id()
+	id, err := a.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == old {
+		t.Fatalf("Acquire after lease lost returned occupied id %d", id)
+	}
+	if err = a.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Get(a.key(old)); v != "other" {
+		t.Fatalf("occupied key = %q", v)
+	}
+}
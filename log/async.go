@@ -0,0 +1,212 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy 异步队列满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 等待队列有空位
+	OverflowDropOldest                       // 丢弃队列中最早的一条
+	OverflowDropNewest                       // 丢弃当前这条
+)
+
+// AsyncConfig 异步写文件的配置，零值字段使用默认值
+type AsyncConfig struct {
+	QueueSize     int            // 队列最多缓存的日志条数，默认4096
+	FlushInterval time.Duration  // 定时刷盘间隔，默认1秒
+	FlushSize     int            // 缓冲超过多少字节时写入文件，默认256K
+	Overflow      OverflowPolicy // 队列满时的处理，默认阻塞
+}
+
+// AsyncStats 异步写入的统计，多个文件时为合计
+type AsyncStats struct {
+	Written uint64 // 已写入文件的条数
+	Dropped uint64 // 队列满或关闭后丢弃的条数
+	Errors  uint64 // 写文件失败的次数
+	Queued  int    // 当前队列中的条数
+}
+
+type syncRequest chan error
+
+// asyncWriter 日志先进入有界队列，由后台goroutine合并后写入文件
+type asyncWriter struct {
+	ws  zapcore.WriteSyncer
+	cfg AsyncConfig
+
+	mu     sync.RWMutex // 保护closed，写锁等待Write退出后再关闭队列
+	closed bool
+	queue  chan []byte
+	syncCh chan syncRequest
+	done   chan struct{}
+
+	written, dropped, errors uint64
+}
+
+func newAsyncWriter(ws zapcore.WriteSyncer, cfg AsyncConfig) *asyncWriter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 4096
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.FlushSize <= 0 {
+		cfg.FlushSize = 256 * 1024
+	}
+	w := &asyncWriter{
+		ws:     ws,
+		cfg:    cfg,
+		queue:  make(chan []byte, cfg.QueueSize),
+		syncCh: make(chan syncRequest),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write zap会复用p，这里需要复制一份
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		atomic.AddUint64(&w.dropped, 1)
+		return len(p), nil
+	}
+	entry := append([]byte(nil), p...)
+	switch w.cfg.Overflow {
+	case OverflowDropNewest:
+		select {
+		case w.queue <- entry:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case w.queue <- entry:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-w.queue:
+				atomic.AddUint64(&w.dropped, 1)
+			default:
+			}
+		}
+	default:
+		w.queue <- entry
+	}
+	return len(p), nil
+}
+
+// Sync 等待调用前写入的日志都写到文件并刷盘
+func (w *asyncWriter) Sync() error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return w.ws.Sync()
+	}
+	req := make(syncRequest, 1)
+	w.syncCh <- req
+	w.mu.RUnlock()
+	return <-req
+}
+
+// Close 写完队列中剩余的日志后返回，之后的写入计入Dropped
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	return w.ws.Sync()
+}
+
+func (w *asyncWriter) Stats() AsyncStats {
+	return AsyncStats{
+		Written: atomic.LoadUint64(&w.written),
+		Dropped: atomic.LoadUint64(&w.dropped),
+		Errors:  atomic.LoadUint64(&w.errors),
+		Queued:  len(w.queue),
+	}
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	var (
+		buf bytes.Buffer
+		n   uint64
+	)
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		if _, err := w.ws.Write(buf.Bytes()); err != nil {
+			atomic.AddUint64(&w.errors, 1)
+		}
+		atomic.AddUint64(&w.written, n)
+		buf.Reset()
+		n = 0
+	}
+	add := func(p []byte) {
+		buf.Write(p)
+		n++
+		if buf.Len() >= w.cfg.FlushSize {
+			flush()
+		}
+	}
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case p, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			add(p)
+		case <-ticker.C:
+			flush()
+		case req := <-w.syncCh:
+			// Sync之前进入队列的日志都已经在队列中
+			// 丢弃最早策略下Write也会取队列，不能阻塞等待
+			for i := len(w.queue); i > 0; i-- {
+				select {
+				case p, ok := <-w.queue:
+					if ok {
+						add(p)
+					}
+				default:
+				}
+			}
+			flush()
+			req <- w.ws.Sync()
+		}
+	}
+}
+
+// AsyncStats 异步写入的统计，未开启异步时为零值
+func (l *Logger) AsyncStats() AsyncStats {
+	l.RLock()
+	defer l.RUnlock()
+	var st AsyncStats
+	for _, w := range l.asyncWriters {
+		s := w.Stats()
+		st.Written += s.Written
+		st.Dropped += s.Dropped
+		st.Errors += s.Errors
+		st.Queued += s.Queued
+	}
+	return st
+}
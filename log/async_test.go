@@ -0,0 +1,111 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// slowSyncer 每次写入前等待gate，模拟慢磁盘
+type slowSyncer struct {
+	mu    sync.Mutex
+	gate  chan struct{}
+	lines []string
+}
+
+func (s *slowSyncer) Write(p []byte) (int, error) {
+	<-s.gate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")...)
+	return len(p), nil
+}
+
+func (s *slowSyncer) Sync() error { return nil }
+
+func TestAsyncDropPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		// 第1条已被后台goroutine取出，队列中为2、3，满了之后的处理不同
+		{OverflowDropNewest, []string{"1", "2", "3"}},
+		{OverflowDropOldest, []string{"1", "4", "5"}},
+	} {
+		s := &slowSyncer{gate: make(chan struct{})}
+		w := newAsyncWriter(s, AsyncConfig{QueueSize: 2, FlushSize: 1, Overflow: tc.policy})
+		_, _ = w.Write([]byte("1\n"))
+		for len(w.queue) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for _, line := range []string{"2\n", "3\n", "4\n", "5\n"} {
+			_, _ = w.Write([]byte(line))
+		}
+		if st := w.Stats(); st.Dropped != 2 || st.Queued != 2 {
+			t.Fatalf("policy %d: stats = %+v", tc.policy, st)
+		}
+		close(s.gate)
+		_ = w.Close()
+		if strings.Join(s.lines, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("policy %d: lines = %v, want %v", tc.policy, s.lines, tc.want)
+		}
+		if st := w.Stats(); st.Written != 3 {
+			t.Fatalf("policy %d: written = %d", tc.policy, st.Written)
+		}
+	}
+}
+
+func TestAsyncBlock(t *testing.T) {
+	s := &slowSyncer{gate: make(chan struct{})}
+	w := newAsyncWriter(s, AsyncConfig{QueueSize: 1, FlushSize: 1})
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			_, _ = w.Write([]byte("x\n"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("write should block while queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(s.gate)
+	<-done
+	_ = w.Close()
+	if len(s.lines) != 5 || w.Stats().Dropped != 0 {
+		t.Fatalf("lines = %d, stats = %+v", len(s.lines), w.Stats())
+	}
+}
+
+func TestAsyncLogger(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("async"), SetAsync(AsyncConfig{FlushInterval: time.Hour}))
+	for i := 0; i < 100; i++ {
+		lg.Info("async line", zap.Int("i", i))
+	}
+	file := filepath.Join(dir, "async-info.log")
+	// 刷盘间隔很长，Sync时才写入文件
+	if err := lg.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(t, file, "async line"); n != 100 {
+		t.Fatalf("lines after Sync = %d", n)
+	}
+	lg.Info("before close")
+	_ = lg.Close()
+	if n := countLines(t, file, "before close"); n != 1 {
+		t.Fatal("Close should drain the queue")
+	}
+	if st := lg.AsyncStats(); st.Written != 102 || st.Dropped != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	stdlog "log"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelWriter 每一行作为一条指定级别的日志
+type levelWriter struct {
+	lg    *zap.Logger
+	level zapcore.Level
+
+	mu  sync.Mutex
+	buf bytes.Buffer // 未以换行结尾的内容
+}
+
+// Writer 返回io.Writer，写入的每一行作为一条level级别的日志，不完整的行等到换行或Close时输出
+func (l *Logger) Writer(level zapcore.Level) io.WriteCloser {
+	return NewLevelWriter(l.Logger, level)
+}
+
+// NewLevelWriter 同Writer，用于Named等方法返回的*zap.Logger
+func NewLevelWriter(lg *zap.Logger, level zapcore.Level) io.WriteCloser {
+	return &levelWriter{lg: lg, level: level}
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// 没有换行，放回缓冲
+			w.buf.Write(line)
+			break
+		}
+		w.log(line)
+	}
+	return len(p), nil
+}
+
+// Close 输出剩余不完整的一行
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.log(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	return nil
+}
+
+func (w *levelWriter) log(line []byte) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return
+	}
+	if ce := w.lg.Check(w.level, string(line)); ce != nil {
+		ce.Write()
+	}
+}
+
+// StdLogger 返回写入当前Logger的标准库*log.Logger，用于只接受*log.Logger的第三方库
+func (l *Logger) StdLogger(level zapcore.Level) *stdlog.Logger {
+	return stdlog.New(l.Writer(level), "", 0)
+}
+
+// RedirectStdLog 把标准库log包的默认输出重定向到当前Logger，返回恢复原输出的函数
+func (l *Logger) RedirectStdLog(level zapcore.Level) (func(), error) {
+	return zap.RedirectStdLogAt(l.Logger, level)
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	stdlog "log"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLevelWriter(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("w"))
+	w := lg.Writer(zap.WarnLevel)
+	_, _ = w.Write([]byte("first line\nsecond "))
+	_, _ = w.Write([]byte("line\r\n\npartial"))
+	_ = w.Close()
+	lg.StdLogger(zap.ErrorLevel).Println("from std logger")
+	_ = lg.Close()
+
+	warn := filepath.Join(dir, "w-warn.log")
+	for _, msg := range []string{`"msg":"first line"`, `"msg":"second line"`, `"msg":"partial"`} {
+		if n := countLines(t, warn, msg); n != 1 {
+			t.Fatalf("%s lines = %d", msg, n)
+		}
+	}
+	if n := countLines(t, filepath.Join(dir, "w-error.log"), `"msg":"from std logger"`); n != 1 {
+		t.Fatalf("std logger lines = %d", n)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("std"))
+	restore, err := lg.RedirectStdLog(zap.WarnLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdlog.Print("redirected")
+	restore()
+	_ = lg.Close()
+	if n := countLines(t, filepath.Join(dir, "std-warn.log"), `"msg":"redirected"`); n != 1 {
+		t.Fatalf("redirected lines = %d", n)
+	}
+}
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+
+	maxRequestIDLen = 128
+)
+
+type loggerKey struct{}
+type traceKey struct{}
+
+// TraceContext W3C traceparent中的链路信息，以及请求ID
+type TraceContext struct {
+	RequestID string
+	TraceID   string // 32位十六进制
+	SpanID    string // 16位十六进制，当前服务的span
+	ParentID  string // 上游的span，没有上游时为空
+	Flags     string // 2位十六进制，01表示采样
+}
+
+// Traceparent 向下游传递的traceparent头，下游的parent为当前span
+func (t TraceContext) Traceparent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+func (t TraceContext) fields() []zap.Field {
+	fields := []zap.Field{zap.String("request_id", t.RequestID)}
+	if t.TraceID != "" {
+		fields = append(fields, zap.String("trace_id", t.TraceID), zap.String("span_id", t.SpanID))
+	}
+	return fields
+}
+
+// NewContext 把lg放入ctx，之后FromContext返回它
+func NewContext(ctx context.Context, lg *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, lg)
+}
+
+// FromContext ctx中的logger，没有时返回Default()
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if lg, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+			return lg
+		}
+	}
+	return Default().Logger
+}
+
+// WithContext 给ctx中的logger追加字段，之后通过FromContext写的每行日志都带这些字段
+func WithContext(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(fields...))
+}
+
+// TraceFromContext Middleware放入ctx的链路信息
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	t, ok := ctx.Value(traceKey{}).(TraceContext)
+	return t, ok
+}
+
+// InjectHeaders 调用下游服务时传递X-Request-ID和traceparent
+func InjectHeaders(ctx context.Context, h http.Header) {
+	t, ok := TraceFromContext(ctx)
+	if !ok {
+		return
+	}
+	h.Set(HeaderRequestID, t.RequestID)
+	if t.TraceID != "" {
+		h.Set(HeaderTraceparent, t.Traceparent())
+	}
+}
+
+// Middleware 使用Default()的net/http中间件，见Logger.Middleware
+func Middleware(next http.Handler) http.Handler {
+	return Default().Middleware(next)
+}
+
+// Middleware net/http中间件
+// 沿用请求中的X-Request-ID和traceparent(trace_id不变，生成新的span_id)，没有时生成新的，
+// 响应头返回X-Request-ID，handler中通过FromContext(r.Context())得到带request_id、trace_id、span_id的logger
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := traceFromRequest(r)
+		w.Header().Set(HeaderRequestID, t.RequestID)
+		ctx := context.WithValue(r.Context(), traceKey{}, t)
+		ctx = NewContext(ctx, l.Logger.With(t.fields()...))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func traceFromRequest(r *http.Request) TraceContext {
+	var t TraceContext
+	if traceID, parentID, flags, ok := parseTraceparent(r.Header.Get(HeaderTraceparent)); ok {
+		t.TraceID, t.ParentID, t.Flags = traceID, parentID, flags
+	} else {
+		t.TraceID, t.Flags = randomHex(16), "01"
+	}
+	t.SpanID = randomHex(8)
+	t.RequestID = r.Header.Get(HeaderRequestID)
+	if t.RequestID == "" || len(t.RequestID) > maxRequestIDLen || strings.ContainsAny(t.RequestID, "\r\n") {
+		t.RequestID = randomHex(16)
+	}
+	return t
+}
+
+// parseTraceparent 解析 version-traceid-parentid-flags，全0的id无效
+func parseTraceparent(s string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+	version := parts[0]
+	// 版本00必须正好4段，未来版本允许追加字段
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", "", false
+	}
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if !isHex(traceID, 32) || !isHex(parentID, 16) || !isHex(flags, 2) ||
+		strings.Count(traceID, "0") == 32 || strings.Count(parentID, "0") == 16 {
+		return "", "", "", false
+	}
+	return traceID, parentID, flags, true
+}
+
+// isHex 长度为n的小写十六进制
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestContextLogger(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("ctx"))
+	defer lg.Close()
+	setTestDefault(t, lg)
+
+	ctx := NewContext(context.Background(), lg.Logger)
+	ctx = WithContext(ctx, zap.String("user", "u1"))
+	FromContext(ctx).Info("with fields")
+	if FromContext(context.Background()) != lg.Logger {
+		t.Fatal("empty context should fall back to Default")
+	}
+	_ = lg.Sync()
+	bs, _ := os.ReadFile(filepath.Join(dir, "ctx-info.log"))
+	if !strings.Contains(string(bs), `"user":"u1"`) {
+		t.Fatalf("ctx-info.log = %s", bs)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("mw"))
+	defer lg.Close()
+
+	var trace TraceContext
+	var outgoing http.Header
+	h := lg.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace, _ = TraceFromContext(r.Context())
+		outgoing = http.Header{}
+		InjectHeaders(r.Context(), outgoing)
+		FromContext(r.Context()).Info("handled")
+	}))
+
+	// 沿用上游的请求ID和trace_id
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	req.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get(HeaderRequestID) != "req-1" {
+		t.Fatalf("response request id = %q", rec.Header().Get(HeaderRequestID))
+	}
+	if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.ParentID != "00f067aa0ba902b7" || trace.SpanID == trace.ParentID {
+		t.Fatalf("trace = %+v", trace)
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + trace.SpanID + "-01"; outgoing.Get(HeaderTraceparent) != want {
+		t.Fatalf("outgoing traceparent = %q, want %q", outgoing.Get(HeaderTraceparent), want)
+	}
+	_ = lg.Sync()
+	bs, _ := os.ReadFile(filepath.Join(dir, "mw-info.log"))
+	for _, s := range []string{`"request_id":"req-1"`, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`, `"span_id":"` + trace.SpanID + `"`} {
+		if !strings.Contains(string(bs), s) {
+			t.Fatalf("mw-info.log missing %s: %s", s, bs)
+		}
+	}
+
+	// 无效的traceparent重新生成
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderTraceparent, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if len(trace.TraceID) != 32 || trace.TraceID == "00000000000000000000000000000000" || trace.ParentID != "" {
+		t.Fatalf("trace = %+v", trace)
+	}
+	if len(rec.Header().Get(HeaderRequestID)) != 32 {
+		t.Fatalf("generated request id = %q", rec.Header().Get(HeaderRequestID))
+	}
+}
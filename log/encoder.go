@@ -0,0 +1,289 @@
+package log
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	EncoderJSON    = "json"
+	EncoderConsole = "console"
+	EncoderLogfmt  = "logfmt"
+)
+
+var bufferPool = buffer.NewPool()
+
+func init() {
+	// 注册后zap.Config.Encoding也可以使用logfmt
+	_ = zap.RegisterEncoder(EncoderLogfmt, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return NewLogfmtEncoder(cfg), nil
+	})
+}
+
+// newEncoder 按名称创建编码器
+func newEncoder(name string, cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+	switch name {
+	case "", EncoderJSON:
+		return zapcore.NewJSONEncoder(cfg), nil
+	case EncoderConsole:
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case EncoderLogfmt:
+		return NewLogfmtEncoder(cfg), nil
+	default:
+		return nil, fmt.Errorf("log: unknown encoder %q", name)
+	}
+}
+
+// logfmtEncoder 输出 key=value 格式，值包含空格、引号、等号时加引号
+// 嵌套的对象和数组以JSON形式作为值
+type logfmtEncoder struct {
+	cfg        zapcore.EncoderConfig
+	buf        *buffer.Buffer
+	namespaces []string
+}
+
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: cfg, buf: bufferPool.Get()}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{cfg: enc.cfg, buf: bufferPool.Get()}
+	clone.namespaces = append(clone.namespaces, enc.namespaces...)
+	_, _ = clone.buf.Write(enc.buf.Bytes())
+	return clone
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{cfg: enc.cfg, buf: bufferPool.Get()}
+	if final.cfg.TimeKey != "" && final.cfg.EncodeTime != nil {
+		final.addPrimitive(final.cfg.TimeKey, func(pe zapcore.PrimitiveArrayEncoder) { final.cfg.EncodeTime(ent.Time, pe) })
+	}
+	if final.cfg.LevelKey != "" && final.cfg.EncodeLevel != nil {
+		final.addPrimitive(final.cfg.LevelKey, func(pe zapcore.PrimitiveArrayEncoder) { final.cfg.EncodeLevel(ent.Level, pe) })
+	}
+	if ent.LoggerName != "" && final.cfg.NameKey != "" {
+		final.AddString(final.cfg.NameKey, ent.LoggerName)
+	}
+	if ent.Caller.Defined && final.cfg.CallerKey != "" && final.cfg.EncodeCaller != nil {
+		final.addPrimitive(final.cfg.CallerKey, func(pe zapcore.PrimitiveArrayEncoder) { final.cfg.EncodeCaller(ent.Caller, pe) })
+	}
+	if final.cfg.MessageKey != "" {
+		final.AddString(final.cfg.MessageKey, ent.Message)
+	}
+	if enc.buf.Len() > 0 {
+		final.sep()
+		_, _ = final.buf.Write(enc.buf.Bytes())
+	}
+	final.namespaces = append(final.namespaces, enc.namespaces...)
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+	if ent.Stack != "" && final.cfg.StacktraceKey != "" {
+		final.AddString(final.cfg.StacktraceKey, ent.Stack)
+	}
+	lineEnding := final.cfg.LineEnding
+	if lineEnding == "" {
+		lineEnding = zapcore.DefaultLineEnding
+	}
+	final.buf.AppendString(lineEnding)
+	return final.buf, nil
+}
+
+func (enc *logfmtEncoder) sep() {
+	if enc.buf.Len() > 0 {
+		enc.buf.AppendByte(' ')
+	}
+}
+
+func (enc *logfmtEncoder) key(k string) {
+	enc.sep()
+	for _, ns := range enc.namespaces {
+		enc.buf.AppendString(ns)
+		enc.buf.AppendByte('.')
+	}
+	enc.buf.AppendString(k)
+	enc.buf.AppendByte('=')
+}
+
+func (enc *logfmtEncoder) value(s string) {
+	if s == "" {
+		enc.buf.AppendString(`""`)
+		return
+	}
+	if strings.IndexFunc(s, needsQuote) >= 0 || !utf8.ValidString(s) {
+		enc.buf.AppendString(strconv.Quote(s))
+		return
+	}
+	enc.buf.AppendString(s)
+}
+
+func needsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f
+}
+
+// addPrimitive 用zap的时间/级别/调用方编码函数得到值
+func (enc *logfmtEncoder) addPrimitive(k string, fn func(zapcore.PrimitiveArrayEncoder)) {
+	arr := &primitiveCapture{}
+	fn(arr)
+	enc.key(k)
+	enc.value(strings.Join(arr.elems, ","))
+}
+
+func (enc *logfmtEncoder) addJSON(k string, fn func(zapcore.ObjectEncoder) error) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := fn(m); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(m.Fields["v"])
+	if err != nil {
+		return err
+	}
+	enc.key(k)
+	enc.value(string(bs))
+	return nil
+}
+
+func (enc *logfmtEncoder) AddArray(k string, v zapcore.ArrayMarshaler) error {
+	return enc.addJSON(k, func(o zapcore.ObjectEncoder) error { return o.AddArray("v", v) })
+}
+
+func (enc *logfmtEncoder) AddObject(k string, v zapcore.ObjectMarshaler) error {
+	return enc.addJSON(k, func(o zapcore.ObjectEncoder) error { return o.AddObject("v", v) })
+}
+
+func (enc *logfmtEncoder) AddReflected(k string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	enc.key(k)
+	enc.value(string(bs))
+	return nil
+}
+
+func (enc *logfmtEncoder) AddBinary(k string, v []byte) {
+	enc.AddString(k, base64.StdEncoding.EncodeToString(v))
+}
+
+func (enc *logfmtEncoder) AddByteString(k string, v []byte) {
+	enc.AddString(k, string(v))
+}
+
+func (enc *logfmtEncoder) AddBool(k string, v bool) {
+	enc.key(k)
+	enc.buf.AppendBool(v)
+}
+
+func (enc *logfmtEncoder) AddComplex128(k string, v complex128) {
+	enc.key(k)
+	enc.value(strconv.FormatComplex(v, 'g', -1, 128))
+}
+
+func (enc *logfmtEncoder) AddComplex64(k string, v complex64) {
+	enc.key(k)
+	enc.value(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+
+func (enc *logfmtEncoder) AddDuration(k string, v time.Duration) {
+	if enc.cfg.EncodeDuration == nil {
+		enc.key(k)
+		enc.value(v.String())
+		return
+	}
+	enc.addPrimitive(k, func(pe zapcore.PrimitiveArrayEncoder) { enc.cfg.EncodeDuration(v, pe) })
+}
+
+func (enc *logfmtEncoder) AddFloat64(k string, v float64) {
+	enc.key(k)
+	switch {
+	case math.IsNaN(v), math.IsInf(v, 0):
+		enc.value(strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		enc.buf.AppendFloat(v, 64)
+	}
+}
+
+func (enc *logfmtEncoder) AddFloat32(k string, v float32) {
+	enc.AddFloat64(k, float64(v))
+}
+
+func (enc *logfmtEncoder) AddInt(k string, v int)     { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt32(k string, v int32) { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt16(k string, v int16) { enc.AddInt64(k, int64(v)) }
+func (enc *logfmtEncoder) AddInt8(k string, v int8)   { enc.AddInt64(k, int64(v)) }
+
+func (enc *logfmtEncoder) AddInt64(k string, v int64) {
+	enc.key(k)
+	enc.buf.AppendInt(v)
+}
+
+func (enc *logfmtEncoder) AddString(k, v string) {
+	enc.key(k)
+	enc.value(v)
+}
+
+func (enc *logfmtEncoder) AddTime(k string, v time.Time) {
+	if enc.cfg.EncodeTime == nil {
+		enc.key(k)
+		enc.value(v.Format(time.RFC3339Nano))
+		return
+	}
+	enc.addPrimitive(k, func(pe zapcore.PrimitiveArrayEncoder) { enc.cfg.EncodeTime(v, pe) })
+}
+
+func (enc *logfmtEncoder) AddUint(k string, v uint)       { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint32(k string, v uint32)   { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint16(k string, v uint16)   { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUint8(k string, v uint8)     { enc.AddUint64(k, uint64(v)) }
+func (enc *logfmtEncoder) AddUintptr(k string, v uintptr) { enc.AddUint64(k, uint64(v)) }
+
+func (enc *logfmtEncoder) AddUint64(k string, v uint64) {
+	enc.key(k)
+	enc.buf.AppendUint(v)
+}
+
+// OpenNamespace 之后的key加上 ns. 前缀
+func (enc *logfmtEncoder) OpenNamespace(k string) {
+	enc.namespaces = append(enc.namespaces, k)
+}
+
+// primitiveCapture 收集EncodeTime/EncodeLevel等函数输出的值
+type primitiveCapture struct {
+	elems []string
+}
+
+func (p *primitiveCapture) AppendBool(v bool)         { p.add(strconv.FormatBool(v)) }
+func (p *primitiveCapture) AppendByteString(v []byte) { p.add(string(v)) }
+func (p *primitiveCapture) AppendComplex128(v complex128) {
+	p.add(strconv.FormatComplex(v, 'g', -1, 128))
+}
+func (p *primitiveCapture) AppendComplex64(v complex64) {
+	p.add(strconv.FormatComplex(complex128(v), 'g', -1, 64))
+}
+func (p *primitiveCapture) AppendFloat64(v float64) { p.add(strconv.FormatFloat(v, 'g', -1, 64)) }
+func (p *primitiveCapture) AppendFloat32(v float32) {
+	p.add(strconv.FormatFloat(float64(v), 'g', -1, 32))
+}
+func (p *primitiveCapture) AppendInt(v int)         { p.add(strconv.Itoa(v)) }
+func (p *primitiveCapture) AppendInt64(v int64)     { p.add(strconv.FormatInt(v, 10)) }
+func (p *primitiveCapture) AppendInt32(v int32)     { p.add(strconv.FormatInt(int64(v), 10)) }
+func (p *primitiveCapture) AppendInt16(v int16)     { p.add(strconv.FormatInt(int64(v), 10)) }
+func (p *primitiveCapture) AppendInt8(v int8)       { p.add(strconv.FormatInt(int64(v), 10)) }
+func (p *primitiveCapture) AppendString(v string)   { p.add(v) }
+func (p *primitiveCapture) AppendUint(v uint)       { p.add(strconv.FormatUint(uint64(v), 10)) }
+func (p *primitiveCapture) AppendUint64(v uint64)   { p.add(strconv.FormatUint(v, 10)) }
+func (p *primitiveCapture) AppendUint32(v uint32)   { p.add(strconv.FormatUint(uint64(v), 10)) }
+func (p *primitiveCapture) AppendUint16(v uint16)   { p.add(strconv.FormatUint(uint64(v), 10)) }
+func (p *primitiveCapture) AppendUint8(v uint8)     { p.add(strconv.FormatUint(uint64(v), 10)) }
+func (p *primitiveCapture) AppendUintptr(v uintptr) { p.add(strconv.FormatUint(uint64(v), 10)) }
+func (p *primitiveCapture) add(s string)            { p.elems = append(p.elems, s) }
@@ -0,0 +1,327 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// namedLevel 子logger的级别，未单独设置时跟随全局级别
+type namedLevel struct {
+	set   int32
+	level zap.AtomicLevel
+}
+
+func (n *namedLevel) isSet() bool {
+	return atomic.LoadInt32(&n.set) == 1
+}
+
+// levelCore 根据全局或子logger的级别过滤日志
+type levelCore struct {
+	zapcore.Core
+	lg    *Logger
+	named *namedLevel // nil表示根logger
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	if c.named != nil && c.named.isSet() {
+		return c.named.level.Enabled(lvl)
+	}
+	return c.lg.zapConfig.Level.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), lg: c.lg, named: c.named}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// Named 返回子logger，可以通过SetNamedLevel单独调整它的级别
+func (l *Logger) Named(name string) *zap.Logger {
+	n := l.namedLevel(name)
+	return l.Logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &levelCore{Core: l.core, lg: l, named: n}
+	})).Named(name)
+}
+
+func (l *Logger) namedLevel(name string) *namedLevel {
+	l.levelMu.Lock()
+	defer l.levelMu.Unlock()
+	if l.named == nil {
+		l.named = make(map[string]*namedLevel)
+	}
+	n, ok := l.named[name]
+	if !ok {
+		n = &namedLevel{level: zap.NewAtomicLevel()}
+		l.named[name] = n
+	}
+	return n
+}
+
+// Level 当前全局级别
+func (l *Logger) Level() zapcore.Level {
+	return l.zapConfig.Level.Level()
+}
+
+// SetLevel 修改全局级别，会取消全局的临时级别
+func (l *Logger) SetLevel(level zapcore.Level) {
+	l.levelMu.Lock()
+	defer l.levelMu.Unlock()
+	l.stopOverride("")
+	l.zapConfig.Level.SetLevel(level)
+}
+
+// SetNamedLevel 修改子logger级别，会取消该子logger的临时级别
+func (l *Logger) SetNamedLevel(name string, level zapcore.Level) {
+	n := l.namedLevel(name)
+	l.levelMu.Lock()
+	defer l.levelMu.Unlock()
+	l.stopOverride(name)
+	n.level.SetLevel(level)
+	atomic.StoreInt32(&n.set, 1)
+}
+
+// ResetNamedLevel 子logger恢复跟随全局级别
+func (l *Logger) ResetNamedLevel(name string) {
+	n := l.namedLevel(name)
+	l.levelMu.Lock()
+	defer l.levelMu.Unlock()
+	l.stopOverride(name)
+	atomic.StoreInt32(&n.set, 0)
+}
+
+// SetLevelFor 临时修改级别(name为空表示全局)，d之后恢复为修改前的级别
+// 期间再调用SetLevel/SetNamedLevel会取消恢复
+func (l *Logger) SetLevelFor(name string, level zapcore.Level, d time.Duration) {
+	var n *namedLevel
+	if name != "" {
+		n = l.namedLevel(name)
+	}
+	l.levelMu.Lock()
+	defer l.levelMu.Unlock()
+
+	var restore func()
+	if n == nil {
+		prev := l.zapConfig.Level.Level()
+		if _, ok := l.overrides[name]; ok {
+			// 已经在临时级别中，保留最初的级别
+			restore = l.overrideRestore[name]
+		} else {
+			restore = func() { l.zapConfig.Level.SetLevel(prev) }
+		}
+		l.zapConfig.Level.SetLevel(level)
+	} else {
+		prev, wasSet := n.level.Level(), n.isSet()
+		if _, ok := l.overrides[name]; ok {
+			restore = l.overrideRestore[name]
+		} else {
+			restore = func() {
+				n.level.SetLevel(prev)
+				if !wasSet {
+					atomic.StoreInt32(&n.set, 0)
+				}
+			}
+		}
+		n.level.SetLevel(level)
+		atomic.StoreInt32(&n.set, 1)
+	}
+	l.stopOverride(name)
+
+	if l.overrides == nil {
+		l.overrides = make(map[string]*time.Timer)
+		l.overrideRestore = make(map[string]func())
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		l.levelMu.Lock()
+		defer l.levelMu.Unlock()
+		if l.overrides[name] != t {
+			return
+		}
+		restore()
+		delete(l.overrides, name)
+		delete(l.overrideRestore, name)
+	})
+	l.overrides[name] = t
+	l.overrideRestore[name] = restore
+}
+
+// stopOverride 调用方需持有levelMu
+func (l *Logger) stopOverride(name string) {
+	if t, ok := l.overrides[name]; ok {
+		t.Stop()
+		delete(l.overrides, name)
+		delete(l.overrideRestore, name)
+	}
+}
+
+// Levels 全局级别和单独设置过级别的子logger
+func (l *Logger) Levels() LevelState {
+	l.levelMu.RLock()
+	defer l.levelMu.RUnlock()
+	st := LevelState{Level: l.zapConfig.Level.Level().String(), Named: map[string]string{}}
+	for name, n := range l.named {
+		if n.isSet() {
+			st.Named[name] = n.level.Level().String()
+		}
+	}
+	return st
+}
+
+type LevelState struct {
+	Level string            `json:"level"`
+	Named map[string]string `json:"named,omitempty"`
+}
+
+// levelRequest PUT请求体，也可以用同名的query/form参数
+type levelRequest struct {
+	Name     string `json:"name"`     // 子logger名称，空为全局
+	Level    string `json:"level"`    // 级别；子logger传空字符串表示恢复跟随全局
+	Duration string `json:"duration"` // 临时级别时长，例如10m
+}
+
+// LevelHandler 查看/修改日志级别
+// GET  返回 {"level":"info","named":{"db":"debug"}}
+// PUT  {"level":"debug"}                              修改全局级别
+// PUT  {"name":"db","level":"debug"}                  修改子logger级别
+// PUT  {"name":"db","level":"debug","duration":"10m"} 临时修改，10分钟后恢复
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			if err := l.applyLevelRequest(r); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "only GET and PUT are supported"})
+			return
+		}
+		writeJSON(w, http.StatusOK, l.Levels())
+	})
+}
+
+func (l *Logger) applyLevelRequest(r *http.Request) error {
+	var req levelRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("bad request body: %v", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		req = levelRequest{Name: r.Form.Get("name"), Level: r.Form.Get("level"), Duration: r.Form.Get("duration")}
+	}
+	if req.Level == "" {
+		if req.Name == "" {
+			return fmt.Errorf("level is required")
+		}
+		l.ResetNamedLevel(req.Name)
+		return nil
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		return err
+	}
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("bad duration %q", req.Duration)
+		}
+		l.SetLevelFor(req.Name, level, d)
+		return nil
+	}
+	if req.Name == "" {
+		l.SetLevel(level)
+	} else {
+		l.SetNamedLevel(req.Name, level)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// BindConfigLevel 从config包加载的配置文件中读取级别，配置文件变化时自动更新，Close时取消
+// key格式与config.GetConf一致(文件名.路径)，值可以是级别字符串，或者
+//
+//	level: info
+//	named:
+//	  db: debug
+func (l *Logger) BindConfigLevel(key string) error {
+	if err := l.applyConfigLevel(key); err != nil {
+		return err
+	}
+	file := strings.SplitN(key, ".", 2)[0]
+	cancel := config.OnChange(func(name string) {
+		if name != file {
+			return
+		}
+		if err := l.applyConfigLevel(key); err != nil {
+			l.Warn("[BindConfigLevel] reload level failed", zap.String("key", key), zap.Error(err))
+		}
+	})
+	l.Lock()
+	l.closers = append(l.closers, cancel)
+	l.Unlock()
+	return nil
+}
+
+func (l *Logger) applyConfigLevel(key string) error {
+	var level zapcore.Level
+	switch v := config.GetConf(key).(type) {
+	case string:
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return err
+		}
+		l.SetLevel(level)
+	case map[string]interface{}:
+		if s, ok := v["level"].(string); ok {
+			if err := level.UnmarshalText([]byte(s)); err != nil {
+				return err
+			}
+			l.SetLevel(level)
+		}
+		named := config.GetStringMapStringConf(key + ".named")
+		l.levelMu.RLock()
+		var stale []string
+		for name, n := range l.named {
+			if _, ok := named[name]; !ok && n.isSet() {
+				stale = append(stale, name)
+			}
+		}
+		l.levelMu.RUnlock()
+		for _, name := range stale {
+			l.ResetNamedLevel(name)
+		}
+		for name, s := range named {
+			if err := level.UnmarshalText([]byte(s)); err != nil {
+				return err
+			}
+			l.SetNamedLevel(name, level)
+		}
+	case nil:
+		return fmt.Errorf("config %s not found", key)
+	default:
+		return fmt.Errorf("config %s: unsupported level value %v", key, v)
+	}
+	return nil
+}
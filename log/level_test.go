@@ -0,0 +1,143 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mark-lupp/go-lib/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelHandler(t *testing.T) {
+	lg := NewLogger(SetLogFileDir(t.TempDir()), SetLevel(zap.InfoLevel))
+	defer lg.Close()
+	db := lg.Named("db")
+	h := lg.LevelHandler()
+
+	do := func(method, body string) LevelState {
+		req := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d %s", method, body, rec.Code, rec.Body)
+		}
+		var st LevelState
+		_ = json.Unmarshal(rec.Body.Bytes(), &st)
+		return st
+	}
+
+	if st := do(http.MethodGet, ""); st.Level != "info" {
+		t.Fatalf("GET = %+v", st)
+	}
+	if db.Core().Enabled(zap.DebugLevel) {
+		t.Fatal("named logger should follow global level")
+	}
+	st := do(http.MethodPut, `{"name":"db","level":"debug"}`)
+	if st.Named["db"] != "debug" || !db.Core().Enabled(zap.DebugLevel) || lg.Core().Enabled(zap.DebugLevel) {
+		t.Fatalf("PUT named = %+v", st)
+	}
+	do(http.MethodPut, `{"level":"error"}`)
+	if lg.Core().Enabled(zap.WarnLevel) || !db.Core().Enabled(zap.DebugLevel) {
+		t.Fatal("global level should not affect overridden named logger")
+	}
+	do(http.MethodPut, `{"name":"db","level":""}`)
+	if db.Core().Enabled(zap.WarnLevel) {
+		t.Fatal("reset named logger should follow global level")
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"loud"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad level: %d", rec.Code)
+	}
+}
+
+func TestSetLevelFor(t *testing.T) {
+	lg := NewLogger(SetLogFileDir(t.TempDir()), SetLevel(zap.InfoLevel))
+	defer lg.Close()
+	db := lg.Named("db")
+
+	lg.SetLevelFor("", zap.DebugLevel, 50*time.Millisecond)
+	lg.SetLevelFor("db", zap.ErrorLevel, 50*time.Millisecond)
+	if lg.Level() != zap.DebugLevel || db.Core().Enabled(zap.WarnLevel) {
+		t.Fatal("temporary levels not applied")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if lg.Level() != zap.InfoLevel {
+		t.Fatalf("global level = %v after revert", lg.Level())
+	}
+	if _, ok := lg.Levels().Named["db"]; ok {
+		t.Fatal("named level should revert to inherit")
+	}
+
+	// 临时级别期间显式设置的级别不会被恢复覆盖
+	lg.SetLevelFor("", zap.DebugLevel, 50*time.Millisecond)
+	lg.SetLevel(zap.WarnLevel)
+	time.Sleep(100 * time.Millisecond)
+	if lg.Level() != zap.WarnLevel {
+		t.Fatalf("level = %v", lg.Level())
+	}
+}
+
+func TestBindConfigLevel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logconf.yaml")
+	if err := os.WriteFile(file, []byte("log:\n  level: info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.New(file); err != nil {
+		t.Fatal(err)
+	}
+	lg := NewLogger(SetLogFileDir(t.TempDir()))
+	defer lg.Close()
+	if err := lg.BindConfigLevel("logconf.log"); err != nil {
+		t.Fatal(err)
+	}
+	if lg.Level() != zap.InfoLevel {
+		t.Fatalf("level = %v", lg.Level())
+	}
+
+	if err := os.WriteFile(file, []byte("log:\n  level: warn\n  named:\n    db: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 级别和子logger级别分两步设置，需要都等到
+	reloaded := func(l *Logger, level zapcore.Level, db string) bool {
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if l.Level() == level && l.Levels().Named["db"] == db {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+	if !reloaded(lg, zap.WarnLevel, "debug") {
+		t.Fatalf("levels after reload = %+v", lg.Levels())
+	}
+
+	// Close之后不再跟随配置变化
+	lg2 := NewLogger(SetLogFileDir(t.TempDir()))
+	defer lg2.Close()
+	if err := lg2.BindConfigLevel("logconf.log"); err != nil {
+		t.Fatal(err)
+	}
+	_ = lg.Close()
+	if err := os.WriteFile(file, []byte("log:\n  level: error\n  named:\n    db: info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded(lg2, zap.ErrorLevel, "info") {
+		t.Fatalf("levels after reload = %+v", lg2.Levels())
+	}
+	if lg.Level() != zap.WarnLevel || lg.Levels().Named["db"] != "debug" {
+		t.Fatalf("closed logger changed: %+v", lg.Levels())
+	}
+}
@@ -1,8 +1,10 @@
 package log
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,21 +22,62 @@ type Options struct {
 	InfoFileName  string        // info日志文件名
 	DebugFileName string        // debug日志文件名
 	Level         zapcore.Level //日志等级
-	MaxSize       int           //日志文件小大（M）
+	MaxSize       int           //日志文件小大（M），使用RotateInterval、MaxTotalSize或RotateHook时0表示不按大小滚动，否则0按100M处理
 	MaxBackups    int           // 最多存在多少个切片文件
 	MaxAge        int           //保存的最大天数
 	Development   bool          // 是否调试模式(控制台打印日志)
+
+	Layout         FileLayout // 文件布局，默认按级别分文件
+	FileName       string     // LayoutSingle时的文件名
+	Routes         []Route    // LayoutRange时的级别区间路由
+	FileEncoder    string     // 文件编码 json/console/logfmt，默认json
+	ConsoleEncoder string     // 控制台编码 json/console/logfmt，默认console
+
+	RotateInterval RotateInterval    // 按时间滚动(与MaxSize先到先触发)，默认只按大小
+	MaxTotalSize   int               // 所有备份文件加当前文件的总大小上限（M），0不限制
+	RotateHook     func(path string) // 滚动完成(压缩后)的回调，例如上传到对象存储
+
+	LevelSampling map[zapcore.Level]SamplingRule // 按级别采样，与zap.Config.Sampling同时设置时都生效
+	RateLimit     RateLimit                      // 同一条消息的限流
+	Dedup         time.Duration                  // 去重窗口，窗口内重复的消息只输出第一条，结束时输出"repeated N times"
+
+	Redaction *Redaction // 脱敏规则，nil不脱敏(Redacted字段仍然遮盖)
+
+	Async *AsyncConfig // 异步写文件，nil为同步写
+	Sinks []Sink       // 远程输出：syslog、TCP、HTTP批量
+
+	// 以下字段非零值时覆盖Development对应的默认配置:
+	// DisableCaller、DisableStacktrace、Sampling、Encoding、EncoderConfig(MessageKey非空时)、InitialFields
+	// OutputPaths 额外的输出(所有级别)，ErrorOutputPaths zap内部错误输出
 	zap.Config
 }
 
 type ModOptions func(options *Options)
 
+// FileLayout 日志文件的划分方式
+type FileLayout int
+
+const (
+	LayoutPerLevel FileLayout = iota // error/warn/info/debug各一个文件
+	LayoutSingle                     // 所有级别写入同一个文件
+	LayoutRange                      // 按Routes中的级别区间写入不同文件
+)
+
+// Route 把[MinLevel, MaxLevel]区间的日志写入AppName-FileName
+type Route struct {
+	FileName string
+	MinLevel zapcore.Level
+	MaxLevel zapcore.Level
+	Encoder  string // 为空时使用Options.FileEncoder
+}
+
 var (
-	l                              *Logger
-	sp                             = string(filepath.Separator)
-	errWS, warnWS, infoWS, debugWS zapcore.WriteSyncer       // IO输出
-	debugConsoleWS                 = zapcore.Lock(os.Stdout) // 控制台标准输出
-	errorConsoleWS                 = zapcore.Lock(os.Stderr)
+	sp             = string(filepath.Separator)
+	debugConsoleWS = zapcore.Lock(os.Stdout) // 控制台标准输出
+	errorConsoleWS = zapcore.Lock(os.Stderr)
+
+	defaultMu     sync.RWMutex
+	defaultLogger *Logger
 )
 
 type Logger struct {
@@ -42,38 +85,83 @@ type Logger struct {
 	sync.RWMutex
 	Opts      *Options `json:"opts"`
 	zapConfig zap.Config
-	inited    bool
+
+	writers      []*sharedWriter // IO输出
+	asyncWriters []*asyncWriter  // 开启异步时每个文件一个
+	closers      []func()        // OutputPaths打开的输出、异步写入、远程输出、去重限流的后台goroutine
+	core         zapcore.Core
+
+	levelMu         sync.RWMutex
+	named           map[string]*namedLevel // 子logger级别
+	overrides       map[string]*time.Timer // 临时级别的恢复定时器，""为全局
+	overrideRestore map[string]func()
+}
+
+// Default 进程级别的Logger，未调用SetDefault时使用默认配置创建
+func Default() *Logger {
+	defaultMu.RLock()
+	lg := defaultLogger
+	defaultMu.RUnlock()
+	if lg != nil {
+		return lg
+	}
+
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultLogger == nil {
+		defaultLogger = NewLogger()
+	}
+	return defaultLogger
+}
+
+// SetDefault 替换进程级别的Logger
+func SetDefault(lg *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = lg
 }
 
-func NewLogger(mod ...ModOptions) *zap.Logger {
-	l = &Logger{}
+// NewZapLogger 与旧版NewLogger相同，返回*zap.Logger
+// 迁移：NewLogger现在返回*Logger，它内嵌*zap.Logger，日志方法不变；需要*zap.Logger时使用lg.Logger或本函数
+// 本函数返回的logger无法Close，进程退出前调用Sync
+func NewZapLogger(mod ...ModOptions) *zap.Logger {
+	return NewLogger(mod...).Logger
+}
+
+// NewLogger 每次调用返回独立的Logger
+// 多个Logger写同一个文件时共用一个滚动写入器，不会各自打开文件互相覆盖
+func NewLogger(mod ...ModOptions) *Logger {
+	l := &Logger{}
 	l.Lock()
 	defer l.Unlock()
-	if l.inited {
-		l.Info("[NewLogger] logger Inited")
-		return nil
-	}
 	l.Opts = &Options{
-		LogFileDir:    "",
-		AppName:       "app_log",
-		ErrorFileName: "error.log",
-		WarnFileName:  "warn.log",
-		InfoFileName:  "info.log",
-		DebugFileName: "debug.log",
-		Level:         zapcore.DebugLevel,
-		MaxSize:       100,
-		MaxBackups:    60,
-		MaxAge:        30,
-		Development:   false,
+		LogFileDir:     "",
+		AppName:        "app_log",
+		ErrorFileName:  "error.log",
+		WarnFileName:   "warn.log",
+		InfoFileName:   "info.log",
+		DebugFileName:  "debug.log",
+		Level:          zapcore.DebugLevel,
+		MaxSize:        100,
+		MaxBackups:     60,
+		MaxAge:         30,
+		Development:    false,
+		Layout:         LayoutPerLevel,
+		FileName:       "all.log",
+		FileEncoder:    EncoderJSON,
+		ConsoleEncoder: EncoderConsole,
+	}
+	// 先应用自定义配置，后面的默认值和目录都依赖最终配置
+	for _, fn := range mod {
+		fn(l.Opts)
 	}
 	if l.Opts.LogFileDir == "" {
 		l.Opts.LogFileDir, _ = filepath.Abs(filepath.Dir(filepath.Join(".")))
 		l.Opts.LogFileDir += sp + "logs" + sp
-	} else {
-		// 判断是否有LogFileDir文件夹
-		if ok, _ := file.PathExists(l.Opts.LogFileDir); !ok {
-			_ = os.Mkdir(l.Opts.LogFileDir, os.ModePerm)
-		}
+	}
+	// 判断是否有LogFileDir文件夹
+	if err := file.CreateDir(l.Opts.LogFileDir); err != nil {
+		panic(err)
 	}
 
 	if l.Opts.Development {
@@ -83,52 +171,125 @@ func NewLogger(mod ...ModOptions) *zap.Logger {
 		l.zapConfig = zap.NewProductionConfig()
 		l.zapConfig.EncoderConfig.EncodeTime = timeUnixNano
 	}
-	if l.Opts.OutputPaths == nil || len(l.Opts.OutputPaths) == 0 {
-		l.zapConfig.OutputPaths = []string{"stdout"}
-	}
-	if l.Opts.ErrorOutputPaths == nil || len(l.Opts.ErrorOutputPaths) == 0 {
-		l.zapConfig.OutputPaths = []string{"stderr"}
-	}
-	for _, fn := range mod {
-		fn(l.Opts)
-	}
+	l.mergeZapConfig()
 	l.zapConfig.Level.SetLevel(l.Opts.Level)
 	l.init()
-	l.inited = true
 	l.Info("[NewLogger] success")
-	return l.Logger
+	return l
 }
 
-func (l *Logger) init() {
-	l.setSyncers()
+// mergeZapConfig 用Options中显式设置的zap.Config字段覆盖默认配置
+// 默认配置中的OutputPaths和Sampling从未生效过，只有显式设置时才启用
+func (l *Logger) mergeZapConfig() {
+	c := l.Opts.Config
+	if c.DisableCaller {
+		l.zapConfig.DisableCaller = true
+	}
+	if c.DisableStacktrace {
+		l.zapConfig.DisableStacktrace = true
+	}
+	l.zapConfig.Sampling = c.Sampling
+	if c.Encoding != "" {
+		l.zapConfig.Encoding = c.Encoding
+	} else {
+		l.zapConfig.Encoding = l.Opts.FileEncoder
+	}
+	if c.EncoderConfig.MessageKey != "" {
+		l.zapConfig.EncoderConfig = c.EncoderConfig
+	}
+	l.zapConfig.OutputPaths = c.OutputPaths
+	if len(c.ErrorOutputPaths) > 0 {
+		l.zapConfig.ErrorOutputPaths = c.ErrorOutputPaths
+	}
+	if len(c.InitialFields) > 0 {
+		l.zapConfig.InitialFields = c.InitialFields
+	}
+}
+
+// Close 刷新缓冲并释放文件，共用的文件在最后一个Logger关闭时才真正关闭
+func (l *Logger) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	// 先按注册的倒序执行closers，去重的汇总需要在文件关闭前写入
+	for i := len(l.closers) - 1; i >= 0; i-- {
+		l.closers[i]()
+	}
+	l.closers = nil
+	_ = l.Logger.Sync()
 	var err error
-	l.Logger, err = l.zapConfig.Build(l.cores())
+	for _, w := range l.writers {
+		if e := w.release(); e != nil {
+			err = e
+		}
+	}
+	l.writers = nil
+	return err
+}
+
+func (l *Logger) init() {
+	cores, err := l.cores()
+	if err != nil {
+		panic(err)
+	}
+	// OutputPaths、Sampling、InitialFields由cores处理，WrapCore会丢弃Build中的设置
+	cfg := l.zapConfig
+	cfg.OutputPaths = nil
+	cfg.Sampling = nil
+	cfg.InitialFields = nil
+	l.Logger, err = cfg.Build(cores)
 	if err != nil {
 		panic(err)
 	}
 	defer l.Logger.Sync()
 }
 
-func (l *Logger) setSyncers() {
-	f := func(fN string) zapcore.WriteSyncer {
-		return zapcore.AddSync(&lumberjack.Logger{
-			Filename:   l.Opts.LogFileDir + sp + l.Opts.AppName + "-" + fN,
+// routes 按文件布局得到每个文件负责的级别区间
+func (l *Logger) routes() []Route {
+	switch l.Opts.Layout {
+	case LayoutSingle:
+		return []Route{{FileName: l.Opts.FileName, MinLevel: zapcore.DebugLevel, MaxLevel: zapcore.FatalLevel}}
+	case LayoutRange:
+		return l.Opts.Routes
+	default:
+		return []Route{
+			{FileName: l.Opts.ErrorFileName, MinLevel: zapcore.ErrorLevel, MaxLevel: zapcore.FatalLevel},
+			{FileName: l.Opts.WarnFileName, MinLevel: zapcore.WarnLevel, MaxLevel: zapcore.WarnLevel},
+			{FileName: l.Opts.InfoFileName, MinLevel: zapcore.InfoLevel, MaxLevel: zapcore.InfoLevel},
+			{FileName: l.Opts.DebugFileName, MinLevel: zapcore.DebugLevel, MaxLevel: zapcore.DebugLevel},
+		}
+	}
+}
+
+// openSyncer 只按大小滚动时使用lumberjack，设置了按时间滚动、总大小限制或滚动回调时使用rotateWriter
+func (l *Logger) openSyncer(fN string) zapcore.WriteSyncer {
+	filename := l.Opts.LogFileDir + sp + l.Opts.AppName + "-" + fN
+	w := openWriter(filename, func() io.WriteCloser {
+		if l.Opts.RotateInterval != RotateNone || l.Opts.MaxTotalSize > 0 || l.Opts.RotateHook != nil {
+			return newRotateWriter(l.Opts, filename)
+		}
+		return &lumberjack.Logger{
+			Filename:   filename,
 			MaxSize:    l.Opts.MaxSize,
 			MaxBackups: l.Opts.MaxBackups,
 			MaxAge:     l.Opts.MaxAge,
 			Compress:   true,
 			LocalTime:  true,
-		})
+		}
+	})
+	l.writers = append(l.writers, w)
+	if l.Opts.Async == nil {
+		return zapcore.AddSync(w)
 	}
-	errWS = f(l.Opts.ErrorFileName)
-	warnWS = f(l.Opts.WarnFileName)
-	infoWS = f(l.Opts.InfoFileName)
-	debugWS = f(l.Opts.DebugFileName)
-	return
+	// 异步写入器属于当前Logger，Close时先写完队列再释放文件
+	aw := newAsyncWriter(w, *l.Opts.Async)
+	l.asyncWriters = append(l.asyncWriters, aw)
+	l.closers = append(l.closers, func() { _ = aw.Close() })
+	return aw
 }
 
 /*
 	设置日志文件的最大大小（M），默认100M
+	注意：设置了RotateInterval、MaxTotalSize或RotateHook时，0表示不按大小滚动；否则与lumberjack一致，0按100M处理
 */
 func SetMaxSize(MaxSize int) ModOptions {
 	return func(option *Options) {
@@ -226,43 +387,210 @@ func SetDevelopment(Development bool) ModOptions {
 	}
 }
 
-func (l *Logger) cores() zap.Option {
-	fileEncoder := zapcore.NewJSONEncoder(l.zapConfig.EncoderConfig)
-	//consoleEncoder := zapcore.NewConsoleEncoder(l.zapConfig.EncoderConfig)
-	encoderConfig := zap.NewDevelopmentEncoderConfig()
-	encoderConfig.EncodeTime = timeEncoder
-	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
+/*
+	设置按时间滚动，RotateHourly/RotateDaily，同时仍受MaxSize限制
+*/
+func SetRotateInterval(RotateInterval RotateInterval) ModOptions {
+	return func(option *Options) {
+		option.RotateInterval = RotateInterval
+	}
+}
 
-	errPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl == zapcore.ErrorLevel && zapcore.ErrorLevel-l.zapConfig.Level.Level() > -1
-	})
-	warnPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl == zapcore.WarnLevel && zapcore.WarnLevel-l.zapConfig.Level.Level() > -1
-	})
-	infoPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl == zapcore.InfoLevel && zapcore.InfoLevel-l.zapConfig.Level.Level() > -1
-	})
-	debugPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl == zapcore.DebugLevel && zapcore.DebugLevel-l.zapConfig.Level.Level() > -1
-	})
-	cores := []zapcore.Core{
-		zapcore.NewCore(fileEncoder, errWS, errPriority),
-		zapcore.NewCore(fileEncoder, warnWS, warnPriority),
-		zapcore.NewCore(fileEncoder, infoWS, infoPriority),
-		zapcore.NewCore(fileEncoder, debugWS, debugPriority),
+/*
+	设置日志文件总大小上限（M），超出时删除最旧的备份
+*/
+func SetMaxTotalSize(MaxTotalSize int) ModOptions {
+	return func(option *Options) {
+		option.MaxTotalSize = MaxTotalSize
+	}
+}
+
+/*
+	设置滚动完成后的回调，参数为备份文件路径，在后台goroutine中调用
+*/
+func SetRotateHook(RotateHook func(path string)) ModOptions {
+	return func(option *Options) {
+		option.RotateHook = RotateHook
 	}
+}
+
+/*
+	设置某个级别的采样，每tick内同一条消息先输出前initial条，之后每thereafter条输出一条
+*/
+func SetLevelSampling(Level zapcore.Level, Initial, Thereafter int, Tick time.Duration) ModOptions {
+	return func(option *Options) {
+		if option.LevelSampling == nil {
+			option.LevelSampling = make(map[zapcore.Level]SamplingRule)
+		}
+		option.LevelSampling[Level] = SamplingRule{Initial: Initial, Thereafter: Thereafter, Tick: Tick}
+	}
+}
+
+/*
+	设置同一条消息每interval最多输出limit条，summary为true时输出被丢弃的条数
+*/
+func SetRateLimit(Limit int, Interval time.Duration, Summary bool) ModOptions {
+	return func(option *Options) {
+		option.RateLimit = RateLimit{Limit: Limit, Interval: Interval, Summary: Summary}
+	}
+}
+
+/*
+	设置去重窗口，窗口内重复的消息合并为一条"repeated N times"汇总
+*/
+func SetDedup(Dedup time.Duration) ModOptions {
+	return func(option *Options) {
+		option.Dedup = Dedup
+	}
+}
+
+/*
+	设置脱敏规则，一般使用DefaultRedaction()并按需追加
+*/
+func SetRedaction(Redaction *Redaction) ModOptions {
+	return func(option *Options) {
+		option.Redaction = Redaction
+	}
+}
+
+/*
+	设置异步写文件，日志先进入有界队列，由后台goroutine批量写入
+*/
+func SetAsync(Async AsyncConfig) ModOptions {
+	return func(option *Options) {
+		option.Async = &Async
+	}
+}
+
+/*
+	添加远程输出，output由NewSyslogOutput、NewTCPOutput、NewUDPOutput、NewHTTPOutput创建
+*/
+func AddSink(Sink Sink) ModOptions {
+	return func(option *Options) {
+		option.Sinks = append(option.Sinks, Sink)
+	}
+}
+
+/*
+	设置单文件布局，所有级别写入AppName-FileName
+*/
+func SetSingleFile(FileName string) ModOptions {
+	return func(option *Options) {
+		option.Layout = LayoutSingle
+		option.FileName = FileName
+	}
+}
+
+/*
+	设置按级别区间路由，例如warn及以上写入一个文件、其余写入另一个文件
+*/
+func SetRoutes(Routes ...Route) ModOptions {
+	return func(option *Options) {
+		option.Layout = LayoutRange
+		option.Routes = Routes
+	}
+}
+
+/*
+	设置文件编码 json/console/logfmt
+*/
+func SetFileEncoder(FileEncoder string) ModOptions {
+	return func(option *Options) {
+		option.FileEncoder = FileEncoder
+	}
+}
+
+/*
+	设置控制台编码 json/console/logfmt
+*/
+func SetConsoleEncoder(ConsoleEncoder string) ModOptions {
+	return func(option *Options) {
+		option.ConsoleEncoder = ConsoleEncoder
+	}
+}
+
+func levelRange(min, max zapcore.Level) zap.LevelEnablerFunc {
+	return func(lvl zapcore.Level) bool {
+		return lvl >= min && lvl <= max
+	}
+}
+
+func (l *Logger) cores() (zap.Option, error) {
+	var cores []zapcore.Core
+	for _, r := range l.routes() {
+		name := r.Encoder
+		if name == "" {
+			name = l.Opts.FileEncoder
+		}
+		enc, err := newEncoder(name, l.zapConfig.EncoderConfig)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, zapcore.NewCore(enc, l.openSyncer(r.FileName), levelRange(r.MinLevel, r.MaxLevel)))
+	}
+	if len(l.zapConfig.OutputPaths) > 0 {
+		ws, closeFn, err := zap.Open(l.zapConfig.OutputPaths...)
+		if err != nil {
+			return nil, err
+		}
+		l.closers = append(l.closers, closeFn)
+		enc, err := newEncoder(l.zapConfig.Encoding, l.zapConfig.EncoderConfig)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, zapcore.NewCore(enc, ws, levelRange(zapcore.DebugLevel, zapcore.FatalLevel)))
+	}
+	sinks, err := l.sinkCores()
+	if err != nil {
+		return nil, err
+	}
+	cores = append(cores, sinks...)
 	if l.Opts.Development {
-		cores = append(cores, []zapcore.Core{
-			zapcore.NewCore(consoleEncoder, errorConsoleWS, errPriority),
-			zapcore.NewCore(consoleEncoder, debugConsoleWS, warnPriority),
-			zapcore.NewCore(consoleEncoder, debugConsoleWS, infoPriority),
-			zapcore.NewCore(consoleEncoder, debugConsoleWS, debugPriority),
-		}...)
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeTime = timeEncoder
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if l.Opts.ConsoleEncoder == EncoderConsole {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		consoleEncoder, err := newEncoder(l.Opts.ConsoleEncoder, encoderConfig)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores,
+			zapcore.NewCore(consoleEncoder, errorConsoleWS, levelRange(zapcore.ErrorLevel, zapcore.FatalLevel)),
+			zapcore.NewCore(consoleEncoder, debugConsoleWS, levelRange(zapcore.DebugLevel, zapcore.WarnLevel)),
+		)
+	}
+	// 各个core只按级别分发到不同输出，是否输出由levelCore根据全局/子logger级别判断
+	l.core = zapcore.NewTee(cores...)
+	core, closers := l.throttleCores(l.core)
+	l.core = core
+	l.closers = append(l.closers, closers...)
+	if len(l.Opts.LevelSampling) > 0 {
+		l.core = newLevelSampler(l.core, l.Opts.LevelSampling)
+	}
+	if s := l.zapConfig.Sampling; s != nil {
+		l.core = zapcore.NewSamplerWithOptions(l.core, time.Second, s.Initial, s.Thereafter)
+	}
+	// 脱敏在去重之前，汇总中带的是脱敏后的字段；InitialFields通过With添加，同样脱敏
+	if l.Opts.Redaction != nil {
+		l.core = &redactCore{Core: l.core, r: l.Opts.Redaction}
+	}
+	if len(l.zapConfig.InitialFields) > 0 {
+		keys := make([]string, 0, len(l.zapConfig.InitialFields))
+		for k := range l.zapConfig.InitialFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]zap.Field, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, zap.Any(k, l.zapConfig.InitialFields[k]))
+		}
+		l.core = l.core.With(fields)
 	}
 	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-		return zapcore.NewTee(cores...)
-	})
+		return &levelCore{Core: l.core, lg: l}
+	}), nil
 }
 
 func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
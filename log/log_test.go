@@ -2,6 +2,9 @@ package log
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,3 +22,118 @@ func TestInitZapV2Logger(t *testing.T) {
 
 	}
 }
+
+func TestNewLoggerIndependent(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	a := NewLogger(SetAppName("a"), SetLogFileDir(dirA))
+	b := NewLogger(SetAppName("b"), SetLogFileDir(dirB))
+	defer a.Close()
+	defer b.Close()
+	if a == b || a.writers[0] == b.writers[0] {
+		t.Fatal("loggers should not share syncers")
+	}
+	a.Info("from a")
+	b.Info("from b")
+	if bs, _ := os.ReadFile(filepath.Join(dirA, "a-info.log")); !strings.Contains(string(bs), "from a") || strings.Contains(string(bs), "from b") {
+		t.Fatalf("a-info.log = %s", bs)
+	}
+}
+
+func TestNewZapLogger(t *testing.T) {
+	dir := t.TempDir()
+	var lg *zap.Logger = NewZapLogger(SetAppName("z"), SetLogFileDir(dir))
+	lg.Info("from zap")
+	_ = lg.Sync()
+	if bs, _ := os.ReadFile(filepath.Join(dir, "z-info.log")); !strings.Contains(string(bs), "from zap") {
+		t.Fatalf("z-info.log = %s", bs)
+	}
+}
+
+func TestNewLoggerShareWriter(t *testing.T) {
+	dir := t.TempDir()
+	a := NewLogger(SetAppName("same"), SetLogFileDir(dir))
+	b := NewLogger(SetAppName("same"), SetLogFileDir(dir))
+	if a.writers[0] != b.writers[0] || a.writers[0].refs != 2 {
+		t.Fatal("same file should share one writer")
+	}
+	_ = a.Close()
+	b.Info("still writable")
+	_ = b.Close()
+	if bs, _ := os.ReadFile(filepath.Join(dir, "same-info.log")); !strings.Contains(string(bs), "still writable") {
+		t.Fatalf("same-info.log = %s", bs)
+	}
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	if _, ok := writers[filepath.Join(dir, "same-info.log")]; ok {
+		t.Fatal("writer should be released")
+	}
+}
+
+// setTestDefault 把lg设为Default，测试结束后恢复
+// 不调用Default()取旧值，避免按默认配置在./logs下创建文件
+func setTestDefault(t *testing.T, lg *Logger) {
+	t.Helper()
+	defaultMu.RLock()
+	old := defaultLogger
+	defaultMu.RUnlock()
+	SetDefault(lg)
+	t.Cleanup(func() { SetDefault(old) })
+}
+
+func TestDefault(t *testing.T) {
+	lg := NewLogger(SetLogFileDir(t.TempDir()))
+	defer lg.Close()
+	setTestDefault(t, lg)
+	if Default() != lg {
+		t.Fatal("SetDefault not applied")
+	}
+}
+
+func TestLayoutAndEncoders(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "logs")
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("r"), SetRoutes(
+		Route{FileName: "alert.log", MinLevel: zap.WarnLevel, MaxLevel: zap.FatalLevel, Encoder: EncoderLogfmt},
+		Route{FileName: "all.log", MinLevel: zap.DebugLevel, MaxLevel: zap.FatalLevel},
+	))
+	lg.Info("hello world", zap.String("user", "bob"), zap.Int("n", 3))
+	lg.Warn("disk almost full", zap.String("path", "/data x"))
+	_ = lg.Close()
+
+	alert, err := os.ReadFile(filepath.Join(dir, "r-alert.log"))
+	if err != nil {
+		t.Fatalf("log dir should be created: %v", err)
+	}
+	if strings.Contains(string(alert), "hello world") || !strings.Contains(string(alert), `msg="disk almost full" path="/data x"`) {
+		t.Fatalf("r-alert.log = %s", alert)
+	}
+	all, _ := os.ReadFile(filepath.Join(dir, "r-all.log"))
+	if !strings.Contains(string(all), `"msg":"hello world"`) || !strings.Contains(string(all), `"msg":"disk almost full"`) {
+		t.Fatalf("r-all.log = %s", all)
+	}
+
+	single := t.TempDir()
+	lg = NewLogger(SetLogFileDir(single), SetAppName("s"), SetSingleFile("one.log"), SetFileEncoder(EncoderConsole))
+	lg.Debug("debug line")
+	lg.Error("error line")
+	_ = lg.Close()
+	one, _ := os.ReadFile(filepath.Join(single, "s-one.log"))
+	if !strings.Contains(string(one), "debug line") || !strings.Contains(string(one), "error line") {
+		t.Fatalf("s-one.log = %s", one)
+	}
+}
+
+func TestZapConfigOptions(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "extra.log")
+	lg := NewLogger(SetLogFileDir(dir), func(o *Options) {
+		o.OutputPaths = []string{out}
+		o.DisableCaller = true
+		o.InitialFields = map[string]interface{}{"service": "svc"}
+	})
+	lg.Info("to extra")
+	_ = lg.Close()
+	bs, _ := os.ReadFile(out)
+	if !strings.Contains(string(bs), `"service":"svc"`) || strings.Contains(string(bs), `"caller"`) {
+		t.Fatalf("extra.log = %s", bs)
+	}
+}
@@ -0,0 +1,61 @@
+// Package logtest 测试中检查日志输出
+package logtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mark-lupp/go-lib/log"
+)
+
+// New 写入临时目录单个文件的Logger，测试结束时关闭
+func New(t testing.TB, mod ...log.ModOptions) *log.Logger {
+	t.Helper()
+	opts := append([]log.ModOptions{log.SetLogFileDir(t.TempDir()), log.SetAppName("test"), log.SetSingleFile("all.log")}, mod...)
+	lg := log.NewLogger(opts...)
+	t.Cleanup(func() { _ = lg.Close() })
+	return lg
+}
+
+// Output 日志目录下所有文件的内容
+func Output(t testing.TB, lg *log.Logger) string {
+	t.Helper()
+	_ = lg.Sync()
+	var sb strings.Builder
+	err := filepath.Walk(lg.Opts.LogFileDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sb.Write(bs)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("logtest: read output: %v", err)
+	}
+	return sb.String()
+}
+
+// AssertNoSensitive 输出中不能包含secrets，也不能有未遮盖的银行卡号、手机号、邮箱等
+// 检查的规则为Logger配置的Redaction，未配置时使用DefaultRedaction
+func AssertNoSensitive(t testing.TB, lg *log.Logger, secrets ...string) {
+	t.Helper()
+	out := Output(t, lg)
+	for _, s := range secrets {
+		if s != "" && strings.Contains(out, s) {
+			t.Errorf("logtest: sensitive value %q reached the log output", s)
+		}
+	}
+	r := lg.Opts.Redaction
+	if r == nil {
+		r = log.DefaultRedaction()
+	}
+	for _, s := range r.Find(out) {
+		t.Errorf("logtest: unmasked value %q reached the log output", s)
+	}
+}
@@ -0,0 +1,67 @@
+package log_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Mark-lupp/go-lib/log"
+	"github.com/Mark-lupp/go-lib/log/logtest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type account struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+type login struct {
+	user, token string
+}
+
+func (l login) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("user", l.user)
+	enc.AddString("token", l.token)
+	return nil
+}
+
+func TestRedaction(t *testing.T) {
+	lg := logtest.New(t, log.SetRedaction(log.DefaultRedaction()))
+	child := lg.With(zap.String("authorization", "Bearer abc.def"))
+	child.Info("connect root:S3cret!@tcp(127.0.0.1:3306)/db",
+		zap.String("pwd", "hunter2"),
+		zap.String("note", "card 4111 1111 1111 1111, phone 13812345678, mail bob@example.com"),
+		zap.Any("account", account{Name: "bob", Password: "p@ss", Email: "bob@example.com"}),
+		zap.Object("login", login{user: "bob", token: "tok-123"}),
+		zap.Error(errors.New("dial mysql://root:S3cret!@db:3306 failed")),
+		zap.Int64("ts", 1640995200000),
+		log.Redacted("id_card", "110101199003070000"),
+	)
+	logtest.AssertNoSensitive(t, lg, "S3cret!", "hunter2", "abc.def", "p@ss", "tok-123", "110101199003070000")
+
+	out := logtest.Output(t, lg)
+	for _, want := range []string{`"name":"bob"`, `"user":"bob"`, `"ts":1640995200000`, `root:******@tcp(`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s: %s", want, out)
+		}
+	}
+}
+
+func TestRedactionStyle(t *testing.T) {
+	r := log.DefaultRedaction()
+	r.Style = log.MaskPartial
+	lg := logtest.New(t, log.SetRedaction(r))
+	lg.Info("pay", zap.String("card", "4111111111111111"), zap.String("token", "abcdefghijkl"))
+	out := logtest.Output(t, lg)
+	if !strings.Contains(out, `"card":"******1111"`) || !strings.Contains(out, `"token":"******ijkl"`) {
+		t.Fatalf("output = %s", out)
+	}
+}
+
+func TestRedactedWithoutRules(t *testing.T) {
+	lg := logtest.New(t)
+	lg.Info("user", log.Redacted("password", "hunter2"))
+	logtest.AssertNoSensitive(t, lg, "hunter2")
+}
@@ -0,0 +1,369 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateInterval 按时间滚动的周期
+type RotateInterval int
+
+const (
+	RotateNone   RotateInterval = iota // 只按大小滚动
+	RotateHourly                       // 每小时，备份文件名 app-info-2006-01-02T15.log
+	RotateDaily                        // 每天，备份文件名 app-info-2006-01-02.log
+)
+
+const (
+	megabyte     = 1024 * 1024
+	compressSuff = ".gz"
+)
+
+// 备份文件名中的时间部分，同一周期内按大小多次滚动时追加 .1 .2 ...
+var stampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T\d{2}(-\d{2}-\d{2}\.\d{3})?)?(\.\d+)?$`)
+
+func (r RotateInterval) layout() string {
+	switch r {
+	case RotateHourly:
+		return "2006-01-02T15"
+	case RotateDaily:
+		return "2006-01-02"
+	default:
+		return "2006-01-02T15-04-05.000"
+	}
+}
+
+// truncate 时间所在周期的开始
+func (r RotateInterval) truncate(t time.Time) time.Time {
+	switch r {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+// rotateWriter 按时间或大小(先到先触发)滚动的文件写入器
+// 备份清理按MaxBackups、MaxAge、MaxTotalSize三个条件，任意一个超出即删除最旧的备份
+type rotateWriter struct {
+	filename   string
+	interval   RotateInterval
+	maxSize    int64 // 字节，0不按大小滚动(lumberjack的0表示100M)
+	maxBackups int
+	maxAge     time.Duration
+	maxTotal   int64 // 备份加当前文件的总字节数，0不限制
+	compress   bool
+	localTime  bool
+	hook       func(path string)
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // 当前文件所属周期
+
+	millOnce  sync.Once
+	millMu    sync.Mutex
+	millQueue []string      // 等待处理的备份
+	millCh    chan struct{} // 通知后台有新的备份，容量1，多次通知合并
+	millDone  chan struct{}
+
+	now func() time.Time
+}
+
+func newRotateWriter(o *Options, filename string) *rotateWriter {
+	return &rotateWriter{
+		filename:   filename,
+		interval:   o.RotateInterval,
+		maxSize:    int64(o.MaxSize) * megabyte,
+		maxBackups: o.MaxBackups,
+		maxAge:     time.Duration(o.MaxAge) * 24 * time.Hour,
+		maxTotal:   int64(o.MaxTotalSize) * megabyte,
+		compress:   true,
+		localTime:  true,
+		hook:       o.RotateHook,
+		now:        time.Now,
+	}
+}
+
+func (w *rotateWriter) currentTime() time.Time {
+	if w.localTime {
+		return w.now()
+	}
+	return w.now().UTC()
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.openExisting(); err != nil {
+			return 0, err
+		}
+	}
+	now := w.currentTime()
+	switch {
+	case w.interval != RotateNone && !w.interval.truncate(now).Equal(w.period):
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	case w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize:
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭文件并等待后台压缩/清理完成
+func (w *rotateWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	millCh, millDone := w.millCh, w.millDone
+	w.millCh, w.millDone, w.millOnce = nil, nil, sync.Once{}
+	w.mu.Unlock()
+	if millCh != nil {
+		close(millCh)
+		<-millDone
+	}
+	return err
+}
+
+// openExisting 追加到已存在的文件，周期以文件修改时间为准，跨周期的旧文件在第一次写入时滚动
+func (w *rotateWriter) openExisting() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	mod := info.ModTime()
+	if !w.localTime {
+		mod = mod.UTC()
+	}
+	w.file, w.size, w.period = f, info.Size(), w.interval.truncate(mod)
+	if info.Size() == 0 {
+		w.period = w.interval.truncate(w.currentTime())
+	}
+	return nil
+}
+
+// rotate 调用方需持有w.mu
+func (w *rotateWriter) rotate(now time.Time) error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	stamp := now
+	if w.interval != RotateNone {
+		stamp = w.period
+	}
+	backup := w.backupName(stamp)
+	if err := os.Rename(w.filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w.file, w.size, w.period = f, 0, w.interval.truncate(now)
+	w.mill(backup)
+	return nil
+}
+
+// backupName app-info.log => app-info-2006-01-02.log，重名时追加序号
+func (w *rotateWriter) backupName(t time.Time) string {
+	prefix, ext := w.prefixExt()
+	base := prefix + t.Format(w.interval.layout())
+	name := base + ext
+	for i := 1; exists(name) || exists(name+compressSuff); i++ {
+		name = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	return name
+}
+
+func (w *rotateWriter) prefixExt() (string, string) {
+	ext := filepath.Ext(w.filename)
+	return strings.TrimSuffix(w.filename, ext) + "-", ext
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// mill 后台压缩、清理备份并调用hook，保证同一时刻只有一个goroutine在处理
+// 调用方持有w.mu，不能等待后台：hook较慢或短时间内多次滚动时备份在队列中排队，写日志不受影响
+func (w *rotateWriter) mill(backup string) {
+	w.millOnce.Do(func() {
+		w.millCh = make(chan struct{}, 1)
+		w.millDone = make(chan struct{})
+		go w.millRun(w.millCh, w.millDone)
+	})
+	w.millMu.Lock()
+	w.millQueue = append(w.millQueue, backup)
+	w.millMu.Unlock()
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *rotateWriter) millRun(millCh <-chan struct{}, millDone chan struct{}) {
+	defer close(millDone)
+	for range millCh {
+		w.millPending()
+	}
+	// Close之前加入的备份
+	w.millPending()
+}
+
+func (w *rotateWriter) millPending() {
+	for {
+		w.millMu.Lock()
+		queue := w.millQueue
+		w.millQueue = nil
+		w.millMu.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+		for _, backup := range queue {
+			w.millOne(backup)
+		}
+	}
+}
+
+func (w *rotateWriter) millOne(backup string) {
+	final := backup
+	if w.compress {
+		if err := compressFile(backup); err == nil {
+			final = backup + compressSuff
+		}
+	}
+	w.cleanup()
+	if w.hook != nil && exists(final) {
+		w.hook(final)
+	}
+}
+
+type backupFile struct {
+	path string
+	info os.FileInfo
+}
+
+// backups 按修改时间从新到旧排列的备份文件
+func (w *rotateWriter) backups() ([]backupFile, error) {
+	prefix, ext := w.prefixExt()
+	dir := filepath.Dir(w.filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(prefix)
+	var files []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, base)
+		stamp = strings.TrimSuffix(stamp, compressSuff)
+		if !strings.HasSuffix(stamp, ext) || !stampPattern.MatchString(strings.TrimSuffix(stamp, ext)) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, name), info: info})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().After(files[j].info.ModTime())
+	})
+	return files, nil
+}
+
+func (w *rotateWriter) cleanup() {
+	files, err := w.backups()
+	if err != nil {
+		return
+	}
+	// 总大小包含正在写的文件
+	var total int64
+	if info, err := os.Stat(w.filename); err == nil {
+		total = info.Size()
+	}
+	cutoff := w.currentTime().Add(-w.maxAge)
+	for i, f := range files {
+		remove := (w.maxBackups > 0 && i >= w.maxBackups) ||
+			(w.maxAge > 0 && f.info.ModTime().Before(cutoff)) ||
+			(w.maxTotal > 0 && total+f.info.Size() > w.maxTotal)
+		if remove {
+			_ = os.Remove(f.path)
+			continue
+		}
+		total += f.info.Size()
+	}
+}
+
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	dst := src + compressSuff
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	// 保留原文件的修改时间，清理时按时间排序
+	_ = os.Chtimes(dst, info.ModTime(), info.ModTime())
+	return os.Remove(src)
+}
@@ -0,0 +1,154 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateDaily(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		mu     sync.Mutex
+		hooked []string
+	)
+	w := newRotateWriter(&Options{
+		RotateInterval: RotateDaily,
+		MaxSize:        1,
+		RotateHook: func(path string) {
+			mu.Lock()
+			hooked = append(hooked, filepath.Base(path))
+			mu.Unlock()
+		},
+	}, filepath.Join(dir, "app-info.log"))
+	now := time.Date(2022, 3, 1, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	_, _ = w.Write([]byte("day1\n"))
+	// 同一天内超过大小，按序号滚动
+	_, _ = w.Write([]byte(strings.Repeat("x", megabyte)))
+	now = now.Add(2 * time.Minute)
+	_, _ = w.Write([]byte("day2\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app-info-2022-03-01.1.log.gz", "app-info-2022-03-01.log.gz", "app-info.log"}
+	if got := listDir(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	if bs, _ := os.ReadFile(filepath.Join(dir, "app-info.log")); string(bs) != "day2\n" {
+		t.Fatalf("active file = %q", bs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hooked) != 2 || hooked[0] != "app-info-2022-03-01.log.gz" {
+		t.Fatalf("hook calls = %v", hooked)
+	}
+}
+
+func TestRotateRetention(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app-info.log")
+	// 已有的旧备份和无关文件
+	old := filepath.Join(dir, "app-info-2022-01-01.log.gz")
+	other := filepath.Join(dir, "app-info-extra.log")
+	for _, f := range []string{old, other} {
+		if err := os.WriteFile(f, []byte(strings.Repeat("o", 600*1024)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.Chtimes(old, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+
+	w := newRotateWriter(&Options{RotateInterval: RotateHourly, MaxTotalSize: 1}, name)
+	w.compress = false
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+	_, _ = w.Write([]byte(strings.Repeat("a", 600*1024)))
+	now = now.Add(time.Hour)
+	_, _ = w.Write([]byte("b"))
+	_ = w.Close()
+
+	// 超出1M总预算，删除最旧的备份，无关文件保留
+	want := []string{"app-info-2022-03-01T10.log", "app-info-extra.log", "app-info.log"}
+	if got := listDir(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+}
+
+func TestLoggerRotateOptions(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("r"), SetSingleFile("all.log"), SetRotateInterval(RotateDaily), SetMaxTotalSize(100))
+	defer lg.Close()
+	if _, ok := lg.writers[0].WriteCloser.(*rotateWriter); !ok {
+		t.Fatalf("writer = %T", lg.writers[0].WriteCloser)
+	}
+}
+
+func TestRotateSlowHook(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	w := newRotateWriter(&Options{
+		RotateInterval: RotateHourly,
+		RotateHook: func(string) {
+			<-release
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		},
+	}, filepath.Join(dir, "app-info.log"))
+	w.compress = false
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	w.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	// hook阻塞时连续滚动多次，写入不能被阻塞
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 40; i++ {
+			_, _ = w.Write([]byte("x\n"))
+			mu.Lock()
+			now = now.Add(time.Hour)
+			mu.Unlock()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked by rotate hook")
+	}
+	close(release)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 39 {
+		t.Fatalf("hook calls = %d, want 39", calls)
+	}
+}
@@ -0,0 +1,232 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingRule 每个Tick内同一级别同一条消息先输出前Initial条，之后每Thereafter条输出一条
+type SamplingRule struct {
+	Initial    int
+	Thereafter int
+	Tick       time.Duration // 默认1秒
+}
+
+// levelSampler 按级别使用不同的采样规则，没有规则的级别不采样
+type levelSampler struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+func newLevelSampler(core zapcore.Core, rules map[zapcore.Level]SamplingRule) zapcore.Core {
+	s := &levelSampler{Core: core, samplers: make(map[zapcore.Level]zapcore.Core, len(rules))}
+	for lvl, r := range rules {
+		tick := r.Tick
+		if tick <= 0 {
+			tick = time.Second
+		}
+		s.samplers[lvl] = zapcore.NewSamplerWithOptions(core, tick, r.Initial, r.Thereafter)
+	}
+	return s
+}
+
+func (s *levelSampler) With(fields []zapcore.Field) zapcore.Core {
+	clone := &levelSampler{Core: s.Core.With(fields), samplers: make(map[zapcore.Level]zapcore.Core, len(s.samplers))}
+	for lvl, c := range s.samplers {
+		clone.samplers[lvl] = c.With(fields)
+	}
+	return clone
+}
+
+func (s *levelSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c, ok := s.samplers[ent.Level]; ok {
+		return c.Check(ent, ce)
+	}
+	return s.Core.Check(ent, ce)
+}
+
+// throttleKey 同一logger、级别、消息视为同一条日志
+type throttleKey struct {
+	level zapcore.Level
+	name  string
+	msg   string
+}
+
+type throttleEntry struct {
+	start      time.Time
+	count      int
+	suppressed int
+	// 最后一条被丢弃的日志，用于输出汇总
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// throttle 同一条日志每个interval最多输出limit条，summary为true时在窗口结束后输出被丢弃的条数
+// 同一个Logger派生的core共用一个throttle
+type throttle struct {
+	limit    int
+	interval time.Duration
+	summary  bool
+
+	mu      sync.Mutex
+	entries map[throttleKey]*throttleEntry
+	stop    chan struct{}
+	done    chan struct{}
+	now     func() time.Time
+}
+
+func newThrottle(limit int, interval time.Duration, summary bool) *throttle {
+	t := &throttle{
+		limit:    limit,
+		interval: interval,
+		summary:  summary,
+		entries:  make(map[throttleKey]*throttleEntry),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+	go t.run()
+	return t
+}
+
+// run 没有新日志时也按时输出汇总，并清理过期的记录
+func (t *throttle) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush(false)
+		case <-t.stop:
+			t.flush(true)
+			return
+		}
+	}
+}
+
+// Close 停止后台goroutine并输出剩余的汇总
+func (t *throttle) Close() {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	<-t.done
+}
+
+func (t *throttle) flush(all bool) {
+	now := t.now()
+	var pending []*throttleEntry
+	t.mu.Lock()
+	for k, e := range t.entries {
+		if !all && now.Sub(e.start) < t.interval {
+			continue
+		}
+		if e.suppressed > 0 && t.summary {
+			pending = append(pending, &throttleEntry{suppressed: e.suppressed, core: e.core, ent: e.ent, fields: e.fields})
+		}
+		delete(t.entries, k)
+	}
+	t.mu.Unlock()
+	for _, e := range pending {
+		t.writeSummary(e)
+	}
+}
+
+func (t *throttle) writeSummary(e *throttleEntry) {
+	ent := e.ent
+	ent.Time = t.now()
+	ent.Message = fmt.Sprintf("%s (repeated %d times)", ent.Message, e.suppressed)
+	fields := append(e.fields[:len(e.fields):len(e.fields)], zap.Int("repeated", e.suppressed))
+	if ce := e.core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// allow 返回是否输出；窗口已过期时返回上一个窗口需要输出的汇总
+func (t *throttle) allow(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) (bool, *throttleEntry) {
+	key := throttleKey{level: ent.Level, name: ent.LoggerName, msg: ent.Message}
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.entries[key]
+	var expired *throttleEntry
+	if ok && now.Sub(e.start) >= t.interval {
+		if e.suppressed > 0 && t.summary {
+			expired = &throttleEntry{suppressed: e.suppressed, core: e.core, ent: e.ent, fields: e.fields}
+		}
+		ok = false
+	}
+	if !ok {
+		e = &throttleEntry{start: now}
+		t.entries[key] = e
+	}
+	e.count++
+	if e.count <= t.limit {
+		return true, expired
+	}
+	e.suppressed++
+	e.core, e.ent, e.fields = core, ent, fields
+	return false, expired
+}
+
+// throttleCore 在Write中判断，Check阶段拿不到字段，汇总需要带上原日志的字段
+type throttleCore struct {
+	zapcore.Core
+	t *throttle
+}
+
+func (c *throttleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &throttleCore{Core: c.Core.With(fields), t: c.t}
+}
+
+func (c *throttleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *throttleCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ok, expired := c.t.allow(c.Core, ent, fields)
+	if expired != nil {
+		c.t.writeSummary(expired)
+	}
+	if !ok {
+		return nil
+	}
+	// 经过Check的core会在这里写入，保证下层core(例如按级别分发的tee)的过滤仍然生效
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
+
+// throttleCores 依次包装去重和限流，返回停止后台goroutine的函数
+func (l *Logger) throttleCores(core zapcore.Core) (zapcore.Core, []func()) {
+	var closers []func()
+	if l.Opts.Dedup > 0 {
+		t := newThrottle(1, l.Opts.Dedup, true)
+		core = &throttleCore{Core: core, t: t}
+		closers = append(closers, t.Close)
+	}
+	if r := l.Opts.RateLimit; r.Limit > 0 && r.Interval > 0 {
+		t := newThrottle(r.Limit, r.Interval, r.Summary)
+		core = &throttleCore{Core: core, t: t}
+		closers = append(closers, t.Close)
+	}
+	return core, closers
+}
+
+// RateLimit 同一条消息每Interval最多输出Limit条，Summary为true时输出被丢弃的条数
+type RateLimit struct {
+	Limit    int
+	Interval time.Duration
+	Summary  bool
+}
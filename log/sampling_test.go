@@ -0,0 +1,89 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func countLines(t *testing.T, file, substr string) int {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, line := range strings.Split(string(bs), "\n") {
+		if strings.Contains(line, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLevelSampling(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("s"), SetSingleFile("all.log"),
+		SetLevelSampling(zap.ErrorLevel, 2, 10, time.Hour))
+	for i := 0; i < 25; i++ {
+		lg.Error("db down")
+		lg.Info("request")
+	}
+	_ = lg.Close()
+	file := filepath.Join(dir, "s-all.log")
+	// 第1、2、12、22条
+	if n := countLines(t, file, "db down"); n != 4 {
+		t.Fatalf("sampled error lines = %d", n)
+	}
+	if n := countLines(t, file, `"request"`); n != 25 {
+		t.Fatalf("info lines = %d", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("r"), SetSingleFile("all.log"), SetRateLimit(3, time.Hour, false))
+	for i := 0; i < 10; i++ {
+		lg.Error("db down", zap.Int("i", i))
+		lg.Error("other")
+	}
+	_ = lg.Close()
+	file := filepath.Join(dir, "r-all.log")
+	if n := countLines(t, file, "db down"); n != 3 {
+		t.Fatalf("db down lines = %d", n)
+	}
+	if n := countLines(t, file, `"other"`); n != 3 {
+		t.Fatalf("other lines = %d", n)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("d"), SetDedup(50*time.Millisecond))
+	for i := 0; i < 100; i++ {
+		lg.Error("db down", zap.Int("i", i))
+	}
+	time.Sleep(200 * time.Millisecond)
+	lg.Error("db down", zap.Int("i", 100))
+	lg.Error("db down", zap.Int("i", 101))
+	_ = lg.Close()
+
+	file := filepath.Join(dir, "d-error.log")
+	bs, _ := os.ReadFile(file)
+	// 第一个窗口：首条和后台输出的汇总；第二个窗口：首条和Close时输出的汇总
+	if n := countLines(t, file, `"db down"`); n != 2 {
+		t.Fatalf("first lines = %d: %s", n, bs)
+	}
+	if !strings.Contains(string(bs), `"msg":"db down (repeated 99 times)"`) || !strings.Contains(string(bs), `"i":99,"repeated":99`) {
+		t.Fatalf("missing summary: %s", bs)
+	}
+	if !strings.Contains(string(bs), `"msg":"db down (repeated 1 times)"`) {
+		t.Fatalf("missing summary on close: %s", bs)
+	}
+	if n := countLines(t, filepath.Join(dir, "d-info.log"), "repeated"); n != 0 {
+		t.Fatal("summary should be routed by level")
+	}
+}
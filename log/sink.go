@@ -0,0 +1,349 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Sink 远程日志输出，与文件输出同时生效
+type Sink struct {
+	MinLevel zapcore.Level // 最低级别，零值为info
+	Encoder  string        // json/console/logfmt，默认json；HTTP批量发送需要json
+	Output   SinkOutput
+}
+
+// SinkOutput 接收编码后的一行日志，ent用于syslog优先级、Loki时间戳等
+type SinkOutput interface {
+	WriteEntry(ent zapcore.Entry, line []byte) error
+	Sync() error
+	Close() error
+}
+
+var errSinkClosed = errors.New("log: sink closed")
+
+// sinkCore 编码后交给SinkOutput
+type sinkCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	out SinkOutput
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &sinkCore{LevelEnabler: c.LevelEnabler, enc: c.enc.Clone(), out: c.out}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	err = c.out.WriteEntry(ent, bytes.TrimRight(buf.Bytes(), "\r\n"))
+	buf.Free()
+	return err
+}
+
+func (c *sinkCore) Sync() error {
+	return c.out.Sync()
+}
+
+// connWriter 日志先进入有界队列，由后台goroutine建立连接并发送，拨号和网络阻塞不会影响调用方
+// 写失败时重连一次；连接不上时在redialDelay内直接丢弃，避免每条日志都等待拨号，错误由Sync返回
+type connWriter struct {
+	network     string
+	addr        string
+	dialTimeout time.Duration
+	redialDelay time.Duration
+
+	mu     sync.RWMutex // 保护closed，写锁等待write退出后再关闭队列
+	closed bool
+	queue  chan []byte
+	syncCh chan syncRequest
+	done   chan struct{}
+
+	connMu   sync.Mutex // 保护conn，只有后台goroutine发送
+	conn     net.Conn
+	lastFail time.Time
+	err      error // 上次Sync之后第一个发送失败的错误
+}
+
+func newConnWriter(network, addr string) *connWriter {
+	w := &connWriter{
+		network:     network,
+		addr:        addr,
+		dialTimeout: 3 * time.Second,
+		redialDelay: time.Second,
+		queue:       make(chan []byte, 1024),
+		syncCh:      make(chan syncRequest),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// write 不会阻塞，队列满时丢弃p并返回错误；p之后不能再修改
+func (w *connWriter) write(p []byte) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return errSinkClosed
+	}
+	select {
+	case w.queue <- p:
+		return nil
+	default:
+		return fmt.Errorf("log: %s %s queue full, entry dropped", w.network, w.addr)
+	}
+}
+
+// sync 等待调用前写入的日志都发送完，返回这期间的发送错误
+func (w *connWriter) sync() error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return nil
+	}
+	req := make(syncRequest, 1)
+	w.syncCh <- req
+	w.mu.RUnlock()
+	return <-req
+}
+
+// Close 发送完队列中剩余的日志后关闭连接
+func (w *connWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	w.connMu.Lock()
+	defer w.connMu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *connWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case p, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.send(p)
+		case req := <-w.syncCh:
+			for i := len(w.queue); i > 0; i-- {
+				select {
+				case p, ok := <-w.queue:
+					if ok {
+						w.send(p)
+					}
+				default:
+				}
+			}
+			req <- w.err
+			w.err = nil
+		}
+	}
+}
+
+func (w *connWriter) send(p []byte) {
+	w.connMu.Lock()
+	defer w.connMu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if !w.lastFail.IsZero() && time.Since(w.lastFail) < w.redialDelay {
+				err = fmt.Errorf("log: %s %s unavailable", w.network, w.addr)
+				break
+			}
+			if w.conn, err = net.DialTimeout(w.network, w.addr, w.dialTimeout); err != nil {
+				w.conn = nil
+				w.lastFail = time.Now()
+				break
+			}
+			w.lastFail = time.Time{}
+		}
+		if _, err = w.conn.Write(p); err == nil {
+			return
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// SyslogConfig RFC 5424 syslog
+// Network 为udp、tcp、unix或unixgram；tcp和unix使用RFC 6587的长度前缀分帧
+type SyslogConfig struct {
+	Network  string
+	Addr     string
+	Facility int    // 默认1(user)
+	AppName  string // 默认为可执行文件名
+	Hostname string // 默认为主机名
+}
+
+type syslogOutput struct {
+	cfg     SyslogConfig
+	w       *connWriter
+	framed  bool
+	procID  string
+	appName string
+	host    string
+}
+
+// NewSyslogOutput 第一次写入时才建立连接
+func NewSyslogOutput(cfg SyslogConfig) (SinkOutput, error) {
+	switch cfg.Network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("log: unsupported syslog network %q", cfg.Network)
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 1
+	}
+	if cfg.Facility < 0 || cfg.Facility > 23 {
+		return nil, fmt.Errorf("log: bad syslog facility %d", cfg.Facility)
+	}
+	o := &syslogOutput{
+		cfg:     cfg,
+		w:       newConnWriter(cfg.Network, cfg.Addr),
+		framed:  strings.HasPrefix(cfg.Network, "tcp") || cfg.Network == "unix",
+		procID:  strconv.Itoa(os.Getpid()),
+		appName: cfg.AppName,
+		host:    cfg.Hostname,
+	}
+	if o.appName == "" {
+		o.appName = filepath.Base(os.Args[0])
+	}
+	if o.host == "" {
+		o.host, _ = os.Hostname()
+	}
+	o.appName, o.host = syslogHeader(o.appName, 48), syslogHeader(o.host, 255)
+	return o, nil
+}
+
+// syslogHeader 头部字段只能是可打印ASCII，不能有空格，空值为-
+func syslogHeader(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogSeverity zap级别对应的syslog severity
+func syslogSeverity(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// WriteEntry <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (o *syslogOutput) WriteEntry(ent zapcore.Entry, line []byte) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s - - ", o.cfg.Facility*8+syslogSeverity(ent.Level),
+		ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"), o.host, o.appName, o.procID)
+	b.Write(line)
+	msg := b.Bytes()
+	if o.framed {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return o.w.write(msg)
+}
+
+func (o *syslogOutput) Sync() error { return o.w.sync() }
+
+func (o *syslogOutput) Close() error { return o.w.Close() }
+
+// ndjsonOutput 每行一个JSON对象(NDJSON)
+type ndjsonOutput struct {
+	w *connWriter
+}
+
+// NewTCPOutput 每行一个JSON对象(NDJSON)发送到TCP地址，断开后自动重连
+func NewTCPOutput(addr string) SinkOutput {
+	return &ndjsonOutput{w: newConnWriter("tcp", addr)}
+}
+
+// NewUDPOutput 每条日志一个UDP数据报，内容为一行JSON(以换行结尾)，超过数据报大小上限的日志会发送失败
+func NewUDPOutput(addr string) SinkOutput {
+	return &ndjsonOutput{w: newConnWriter("udp", addr)}
+}
+
+func (o *ndjsonOutput) WriteEntry(_ zapcore.Entry, line []byte) error {
+	return o.w.write(append(line[:len(line):len(line)], '\n'))
+}
+
+func (o *ndjsonOutput) Sync() error { return o.w.sync() }
+
+func (o *ndjsonOutput) Close() error { return o.w.Close() }
+
+// sinkCores 为Options.Sinks创建core
+func (l *Logger) sinkCores() ([]zapcore.Core, error) {
+	var cores []zapcore.Core
+	for _, s := range l.Opts.Sinks {
+		if s.Output == nil {
+			return nil, errors.New("log: sink output is nil")
+		}
+		enc, err := newEncoder(s.Encoder, l.zapConfig.EncoderConfig)
+		if err != nil {
+			return nil, err
+		}
+		out := s.Output
+		cores = append(cores, &sinkCore{LevelEnabler: levelRange(s.MinLevel, zapcore.FatalLevel), enc: enc, out: out})
+		l.closers = append(l.closers, func() { _ = out.Close() })
+	}
+	return cores, nil
+}
@@ -0,0 +1,466 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// HTTPFormat HTTP批量发送的请求体格式
+type HTTPFormat int
+
+const (
+	HTTPFormatNDJSON        HTTPFormat = iota // 每行一条日志
+	HTTPFormatLoki                            // Loki push API，按级别分stream
+	HTTPFormatElasticsearch                   // Elasticsearch _bulk API
+)
+
+// HTTPConfig HTTP批量发送
+// 发送失败时按RetryBackoff指数退避重试MaxRetries次，仍失败且设置了SpoolDir时写入磁盘，之后每次发送前先补发
+// 退避期间日志留在内存中，超过MaxPending条时丢弃最旧的，丢弃的条数由Sync返回
+type HTTPConfig struct {
+	URL           string
+	Format        HTTPFormat
+	Labels        map[string]string // Loki stream标签，会追加level
+	Index         string            // Elasticsearch索引
+	Headers       map[string]string // 例如Authorization
+	BatchSize     int               // 累计多少条发送一次，默认100
+	MaxPending    int               // 内存中最多缓存的条数，默认BatchSize*10
+	FlushInterval time.Duration     // 默认1秒
+	MaxRetries    int               // 默认3
+	RetryBackoff  time.Duration     // 第一次重试的等待时间，默认500ms
+	SpoolDir      string            // 为空时发送失败直接丢弃
+	SpoolMaxSize  int64             // 落盘文件总大小上限(字节)，超出删除最旧的，默认100M
+	Client        *http.Client
+}
+
+type httpEntry struct {
+	ent  zapcore.Entry
+	line []byte
+}
+
+type httpOutput struct {
+	cfg HTTPConfig
+
+	mu      sync.Mutex
+	pending []httpEntry
+	dropped int // 上次Sync之后丢弃的条数
+	closed  bool
+
+	sendMu  sync.Mutex // 同一时刻只有一个批次在发送，保证顺序；不在持锁期间等待重试
+	retries int        // 当前批次连续失败的次数，sendMu保护
+	retryAt time.Time  // 退避结束的时间，sendMu保护
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	spoolID int64
+}
+
+// NewHTTPOutput 后台goroutine定时或累计BatchSize条后发送
+func NewHTTPOutput(cfg HTTPConfig) (SinkOutput, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("log: http sink url is empty")
+	}
+	if cfg.Format == HTTPFormatElasticsearch && cfg.Index == "" {
+		return nil, fmt.Errorf("log: elasticsearch sink needs an index")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = cfg.BatchSize * 10
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
+	}
+	if cfg.SpoolMaxSize <= 0 {
+		cfg.SpoolMaxSize = 100 * megabyte
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.SpoolDir != "" {
+		if err := os.MkdirAll(cfg.SpoolDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	o := &httpOutput{
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go o.run()
+	return o, nil
+}
+
+func (o *httpOutput) WriteEntry(ent zapcore.Entry, line []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return errSinkClosed
+	}
+	o.pending = append(o.pending, httpEntry{ent: ent, line: append([]byte(nil), line...)})
+	o.trimPending()
+	if len(o.pending) >= o.cfg.BatchSize {
+		select {
+		case o.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Sync 立即发送缓存的日志，不等待重试的退避，失败时落盘；上次Sync之后有丢弃的日志时返回错误
+func (o *httpOutput) Sync() error {
+	err := o.flush(flushSync)
+	o.mu.Lock()
+	n := o.dropped
+	o.dropped = 0
+	o.mu.Unlock()
+	if n > 0 {
+		if err == nil {
+			return fmt.Errorf("log: http sink %s dropped %d entries", o.cfg.URL, n)
+		}
+		return fmt.Errorf("%w (%d entries dropped)", err, n)
+	}
+	return err
+}
+
+// Close 发送剩余日志，失败时落盘
+func (o *httpOutput) Close() error {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return nil
+	}
+	o.closed = true
+	o.mu.Unlock()
+	close(o.stop)
+	<-o.done
+	return o.flush(flushClose)
+}
+
+func (o *httpOutput) run() {
+	defer close(o.done)
+	ticker := time.NewTicker(o.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		var retry <-chan time.Time
+		if d := o.retryDelay(); d > 0 {
+			retry = time.After(d)
+		}
+		select {
+		case <-ticker.C:
+		case <-o.kick:
+		case <-retry:
+		case <-o.stop:
+			return
+		}
+		_ = o.flush(flushTick)
+	}
+}
+
+func (o *httpOutput) retryDelay() time.Duration {
+	o.sendMu.Lock()
+	defer o.sendMu.Unlock()
+	if o.retryAt.IsZero() {
+		return 0
+	}
+	return time.Until(o.retryAt)
+}
+
+type flushMode int
+
+const (
+	flushTick  flushMode = iota // 后台定时发送，失败时退避重试
+	flushSync                   // 不等待退避，失败时落盘，没有SpoolDir时放回队列
+	flushClose                  // 只尝试一次，失败时全部落盘或丢弃
+)
+
+// flush 先补发落盘的批次，再按顺序发送缓存
+func (o *httpOutput) flush(mode flushMode) error {
+	o.sendMu.Lock()
+	defer o.sendMu.Unlock()
+	if mode == flushTick && time.Now().Before(o.retryAt) {
+		return nil
+	}
+	if err := o.resendSpool(); err != nil {
+		// 接收端仍不可用，当前批次直接落盘
+		return o.spool(o.take())
+	}
+	for {
+		batch := o.take()
+		if len(batch) == 0 {
+			o.retries, o.retryAt = 0, time.Time{}
+			return nil
+		}
+		body, contentType, err := o.encode(batch)
+		if err != nil {
+			o.drop(len(batch))
+			return err
+		}
+		if err = o.post(body, contentType); err == nil {
+			o.retries, o.retryAt = 0, time.Time{}
+			continue
+		}
+		if _, ok := err.(permanentError); ok {
+			o.drop(len(batch))
+			return err
+		}
+		o.retries++
+		giveUp := mode == flushClose || o.retries > o.cfg.MaxRetries
+		switch {
+		case o.cfg.SpoolDir != "" && (giveUp || mode == flushSync):
+			o.retries, o.retryAt = 0, time.Time{}
+			if err := o.spoolBody(body); err != nil {
+				return err
+			}
+			if mode == flushClose {
+				return o.spool(o.take())
+			}
+			return nil
+		case giveUp:
+			o.retries, o.retryAt = 0, time.Time{}
+			o.drop(len(batch))
+			if mode == flushClose {
+				for batch = o.take(); len(batch) > 0; batch = o.take() {
+					o.drop(len(batch))
+				}
+			}
+			return err
+		default:
+			// 放回队列，由后台goroutine在退避结束后重试
+			o.requeue(batch)
+			o.retryAt = time.Now().Add(o.cfg.RetryBackoff << (o.retries - 1))
+			return err
+		}
+	}
+}
+
+// requeue 把发送失败的批次放回队列头部
+func (o *httpOutput) requeue(batch []httpEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(batch, o.pending...)
+	o.trimPending()
+}
+
+// trimPending 超过MaxPending时丢弃最旧的，调用时需要持有mu
+func (o *httpOutput) trimPending() {
+	if n := len(o.pending) - o.cfg.MaxPending; n > 0 {
+		o.dropped += n
+		o.pending = o.pending[n:]
+	}
+}
+
+func (o *httpOutput) drop(n int) {
+	o.mu.Lock()
+	o.dropped += n
+	o.mu.Unlock()
+}
+
+// take 取出最多BatchSize条
+func (o *httpOutput) take() []httpEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(o.pending)
+	if n > o.cfg.BatchSize {
+		n = o.cfg.BatchSize
+	}
+	batch := o.pending[:n:n]
+	o.pending = o.pending[n:]
+	return batch
+}
+
+func (o *httpOutput) contentType() string {
+	switch o.cfg.Format {
+	case HTTPFormatLoki:
+		return "application/json"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+func (o *httpOutput) encode(batch []httpEntry) ([]byte, string, error) {
+	var b bytes.Buffer
+	switch o.cfg.Format {
+	case HTTPFormatLoki:
+		type stream struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		byLevel := map[zapcore.Level]*stream{}
+		var levels []zapcore.Level
+		for _, e := range batch {
+			s, ok := byLevel[e.ent.Level]
+			if !ok {
+				labels := map[string]string{"level": e.ent.Level.String()}
+				for k, v := range o.cfg.Labels {
+					labels[k] = v
+				}
+				s = &stream{Stream: labels}
+				byLevel[e.ent.Level] = s
+				levels = append(levels, e.ent.Level)
+			}
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.ent.Time.UnixNano(), 10), string(e.line)})
+		}
+		sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+		req := struct {
+			Streams []*stream `json:"streams"`
+		}{}
+		for _, lvl := range levels {
+			req.Streams = append(req.Streams, byLevel[lvl])
+		}
+		if err := json.NewEncoder(&b).Encode(req); err != nil {
+			return nil, "", err
+		}
+	case HTTPFormatElasticsearch:
+		action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": o.cfg.Index}})
+		for _, e := range batch {
+			b.Write(action)
+			b.WriteByte('\n')
+			b.Write(e.line)
+			b.WriteByte('\n')
+		}
+	default:
+		for _, e := range batch {
+			b.Write(e.line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes(), o.contentType(), nil
+}
+
+// permanentError 4xx(429除外)，重试和落盘都没有意义
+type permanentError struct{ error }
+
+func (o *httpOutput) post(body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, o.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range o.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("log: http sink %s: %s", o.cfg.URL, resp.Status)
+	default:
+		return permanentError{fmt.Errorf("log: http sink %s: %s", o.cfg.URL, resp.Status)}
+	}
+}
+
+const spoolSuffix = ".spool"
+
+func (o *httpOutput) spool(batch []httpEntry) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if o.cfg.SpoolDir == "" {
+		return fmt.Errorf("log: http sink %s unavailable, %d entries dropped", o.cfg.URL, len(batch))
+	}
+	body, _, err := o.encode(batch)
+	if err != nil {
+		return err
+	}
+	if err = o.spoolBody(body); err != nil {
+		return err
+	}
+	// 剩下的也落盘，避免接收端长时间不可用时内存增长
+	for batch = o.take(); len(batch) > 0; batch = o.take() {
+		if body, _, err = o.encode(batch); err != nil {
+			return err
+		}
+		if err = o.spoolBody(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spoolBody 文件名按时间递增，补发时按文件名顺序
+func (o *httpOutput) spoolBody(body []byte) error {
+	id := time.Now().UnixNano()
+	if id <= o.spoolID {
+		id = o.spoolID + 1
+	}
+	o.spoolID = id
+	name := filepath.Join(o.cfg.SpoolDir, fmt.Sprintf("%020d%s", id, spoolSuffix))
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	o.trimSpool()
+	return nil
+}
+
+func (o *httpOutput) spoolFiles() []string {
+	if o.cfg.SpoolDir == "" {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(o.cfg.SpoolDir, "*"+spoolSuffix))
+	sort.Strings(files)
+	return files
+}
+
+// trimSpool 超出SpoolMaxSize时删除最旧的文件
+func (o *httpOutput) trimSpool() {
+	files := o.spoolFiles()
+	var total int64
+	for i := len(files) - 1; i >= 0; i-- {
+		info, err := os.Stat(files[i])
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		if total > o.cfg.SpoolMaxSize {
+			_ = os.Remove(files[i])
+		}
+	}
+}
+
+// resendSpool 按顺序补发，只尝试一次，遇到失败时停止，之后的批次需要继续落盘以保证顺序
+func (o *httpOutput) resendSpool() error {
+	for _, f := range o.spoolFiles() {
+		body, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if err = o.post(body, o.contentType()); err != nil {
+			if _, ok := err.(permanentError); !ok {
+				return err
+			}
+		}
+		_ = os.Remove(f)
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var syslogLine = regexp.MustCompile(`^<(\d+)>1 \S+ host app \d+ - - (\{.*\})$`)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	out, err := NewSyslogOutput(SyslogConfig{Network: "udp", Addr: pc.LocalAddr().String(), AppName: "app", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg := NewLogger(SetLogFileDir(t.TempDir()), AddSink(Sink{MinLevel: zap.WarnLevel, Output: out}))
+	lg.Info("not sent")
+	lg.Error("sent", zap.String("k", "v"))
+	_ = lg.Close()
+
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := syslogLine.FindStringSubmatch(string(buf[:n]))
+	// facility user(1)*8 + error(3)
+	if m == nil || m[1] != "11" || !strings.Contains(m[2], `"msg":"sent"`) {
+		t.Fatalf("syslog message = %q", buf[:n])
+	}
+}
+
+// readFramed 读取RFC 6587长度前缀的消息
+func readFramed(r *bufio.Reader) (string, error) {
+	var n int
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == ' ' {
+			break
+		}
+		n = n*10 + int(c-'0')
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func TestSyslogUnixStream(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan string, 3)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(conn)
+		for {
+			m, err := readFramed(r)
+			if err != nil {
+				return
+			}
+			msgs <- m
+		}
+	}()
+	out, _ := NewSyslogOutput(SyslogConfig{Network: "unix", Addr: addr, AppName: "app", Hostname: "host"})
+	lg := NewLogger(SetLogFileDir(t.TempDir()), AddSink(Sink{Output: out}))
+	defer lg.Close()
+	lg.Warn("one")
+	lg.Info("two")
+	// 第一条是NewLogger的info日志
+	for _, want := range []string{`<14>`, `<12>`, `<14>`} {
+		select {
+		case m := <-msgs:
+			if !strings.HasPrefix(m, want) || !syslogLine.MatchString(m) {
+				t.Fatalf("message = %q, want prefix %s", m, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestTCPOutputReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				s := bufio.NewScanner(c)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+				// 读到第一行后服务端断开，客户端需要重连
+				_ = c.Close()
+			}(conn)
+		}
+	}()
+	out := NewTCPOutput(ln.Addr().String())
+	defer out.Close()
+	ent := zapEntry("first")
+	if err := out.WriteEntry(ent, []byte(`{"msg":"first"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-lines; got != `{"msg":"first"}` {
+		t.Fatalf("line = %q", got)
+	}
+	// 断开连接，之后的写入会在失败后重连
+	tw := out.(*ndjsonOutput).w
+	tw.connMu.Lock()
+	_ = tw.conn.Close()
+	tw.connMu.Unlock()
+	if err := out.WriteEntry(ent, []byte(`{"msg":"second"}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-lines:
+		if got != `{"msg":"second"}` {
+			t.Fatalf("line = %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no line after reconnect")
+	}
+}
+
+func TestUDPOutput(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	out := NewUDPOutput(pc.LocalAddr().String())
+	lg := NewLogger(SetLogFileDir(t.TempDir()), AddSink(Sink{MinLevel: zap.WarnLevel, Output: out}))
+	lg.Warn("one", zap.Int("n", 1))
+	lg.Error("two")
+	_ = lg.Close()
+
+	// 每条日志一个数据报
+	buf := make([]byte, 4096)
+	for _, want := range []string{"one", "two"} {
+		_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rec map[string]interface{}
+		if !strings.HasSuffix(string(buf[:n]), "}\n") || json.Unmarshal(buf[:n], &rec) != nil || rec["msg"] != want {
+			t.Fatalf("datagram = %q, want msg %s", buf[:n], want)
+		}
+	}
+}
+
+func TestConnWriterDoesNotBlock(t *testing.T) {
+	// 不可路由的地址，拨号会一直等到超时
+	w := newConnWriter("tcp", "10.255.255.1:9")
+	w.dialTimeout = 300 * time.Millisecond
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := w.write([]byte("x\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("write blocked for %v", d)
+	}
+	// 第一次拨号失败后，其余日志在redialDelay内直接丢弃
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("close waited for every entry to dial")
+	}
+}
+
+func zapEntry(msg string) zapcore.Entry {
+	return zapcore.Entry{Level: zap.InfoLevel, Time: time.Now(), Message: msg}
+}
+
+func TestHTTPOutputSpool(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		down   = true
+		bodies []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		bs, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(bs))
+	}))
+	defer srv.Close()
+
+	spool := t.TempDir()
+	out, err := NewHTTPOutput(HTTPConfig{URL: srv.URL, Format: HTTPFormatLoki, Labels: map[string]string{"app": "t"},
+		FlushInterval: time.Hour, MaxRetries: 1, RetryBackoff: time.Millisecond, SpoolDir: spool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg := NewLogger(SetLogFileDir(t.TempDir()), AddSink(Sink{Output: out}))
+	lg.Info("while down")
+	_ = lg.Sync()
+	if files, _ := filepath.Glob(filepath.Join(spool, "*.spool")); len(files) != 1 {
+		t.Fatalf("spooled files = %v", files)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	lg.Warn("after recovery")
+	_ = lg.Close()
+	if files, _ := filepath.Glob(filepath.Join(spool, "*.spool")); len(files) != 0 {
+		t.Fatalf("spool not drained: %v", files)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 || !strings.Contains(bodies[0], "while down") || !strings.Contains(bodies[1], "after recovery") {
+		t.Fatalf("bodies = %q", bodies)
+	}
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal([]byte(bodies[1]), &push); err != nil || len(push.Streams) != 1 ||
+		push.Streams[0].Stream["level"] != "warn" || push.Streams[0].Stream["app"] != "t" {
+		t.Fatalf("loki body = %s (%v)", bodies[1], err)
+	}
+}
+
+func TestHTTPOutputElasticsearch(t *testing.T) {
+	bodies := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies <- string(bs)
+	}))
+	defer srv.Close()
+	out, err := NewHTTPOutput(HTTPConfig{URL: srv.URL, Format: HTTPFormatElasticsearch, Index: "logs", BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	_ = out.WriteEntry(zapEntry("a"), []byte(`{"msg":"a"}`))
+	_ = out.WriteEntry(zapEntry("b"), []byte(`{"msg":"b"}`))
+	select {
+	case body := <-bodies:
+		want := "{\"index\":{\"_index\":\"logs\"}}\n{\"msg\":\"a\"}\n{\"index\":{\"_index\":\"logs\"}}\n{\"msg\":\"b\"}\n"
+		if body != want {
+			t.Fatalf("bulk body = %q", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("batch size should trigger a send")
+	}
+}
+
+func TestHTTPOutputBounded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	out, err := NewHTTPOutput(HTTPConfig{URL: srv.URL, BatchSize: 2, MaxPending: 5, FlushInterval: time.Hour, RetryBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for i := 0; i < 20; i++ {
+		if err := out.WriteEntry(zapEntry("x"), []byte(`{"msg":"x"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 接收端不可用时不在Sync中等待退避，超出MaxPending的日志被丢弃并由Sync报告
+	start := time.Now()
+	err = out.Sync()
+	if time.Since(start) > time.Second {
+		t.Fatal("sync waited for retry backoff")
+	}
+	if err == nil || !strings.Contains(err.Error(), "15 entries dropped") {
+		t.Fatalf("sync err = %v", err)
+	}
+	o := out.(*httpOutput)
+	o.mu.Lock()
+	n := len(o.pending)
+	o.mu.Unlock()
+	if n != 5 {
+		t.Fatalf("pending = %d, want 5", n)
+	}
+	if err := out.Sync(); err == nil || strings.Contains(err.Error(), "dropped") {
+		t.Fatalf("second sync err = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler 把log/slog的记录写入Logger
+type slogHandler struct {
+	lg     *zap.Logger
+	groups []string
+}
+
+// SlogHandler 返回slog.Handler，slog.Default()也可以通过slog.SetDefault(slog.New(l.SlogHandler()))写入当前Logger
+func (l *Logger) SlogHandler() slog.Handler {
+	return &slogHandler{lg: l.Logger}
+}
+
+func slogLevel(lvl slog.Level) zapcore.Level {
+	switch {
+	case lvl >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case lvl >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case lvl >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.lg.Core().Enabled(slogLevel(lvl))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ce := h.lg.Check(slogLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Time = r.Time
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, frame.PC != 0)
+	}
+	fields := make([]zap.Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, a)
+		return true
+	})
+	ce.Write(h.wrapGroups(fields)...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
+	}
+	return &slogHandler{lg: h.lg.With(h.wrapGroups(fields)...), groups: h.groups}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{lg: h.lg, groups: append(h.groups[:len(h.groups):len(h.groups)], name)}
+}
+
+// wrapGroups WithGroup之后的字段嵌套在分组对象中
+func (h *slogHandler) wrapGroups(fields []zap.Field) []zap.Field {
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		if len(fields) == 0 {
+			return nil
+		}
+		fields = []zap.Field{zap.Object(h.groups[i], fieldsObject(fields))}
+	}
+	return fields
+}
+
+type fieldsObject []zap.Field
+
+func (fs fieldsObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range fs {
+		f.AddTo(enc)
+	}
+	return nil
+}
+
+func appendAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, v.Time()))
+	case slog.KindGroup:
+		var group []zap.Field
+		for _, ga := range v.Group() {
+			group = appendAttr(group, ga)
+		}
+		if len(group) == 0 {
+			return fields
+		}
+		// 空key的分组直接展开
+		if a.Key == "" {
+			return append(fields, group...)
+		}
+		return append(fields, zap.Object(a.Key, fieldsObject(group)))
+	default:
+		if err, ok := v.Any().(error); ok {
+			return append(fields, zap.NamedError(a.Key, err))
+		}
+		return append(fields, zap.Any(a.Key, v.Any()))
+	}
+}
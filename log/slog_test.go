@@ -0,0 +1,38 @@
+//go:build go1.21
+
+package log
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSlogHandler(t *testing.T) {
+	dir := t.TempDir()
+	lg := NewLogger(SetLogFileDir(dir), SetAppName("slog"), SetLevel(zap.InfoLevel))
+	sl := slog.New(lg.SlogHandler()).With("svc", "api").WithGroup("req")
+	sl.Debug("dropped")
+	sl.Info("handled", "status", 200, slog.Group("user", "id", 7))
+	sl.Error("failed", "err", errors.New("boom"))
+	_ = lg.Close()
+
+	bs, _ := os.ReadFile(filepath.Join(dir, "slog-info.log"))
+	if !strings.Contains(string(bs), `"msg":"handled","svc":"api","req":{"status":200,"user":{"id":7}}`) {
+		t.Fatalf("slog-info.log = %s", bs)
+	}
+	if !strings.Contains(string(bs), `"caller":"log/slog_test.go:`) {
+		t.Fatalf("caller should point to the slog call site: %s", bs)
+	}
+	if strings.Contains(string(bs), "dropped") {
+		t.Fatal("debug record should be filtered")
+	}
+	if n := countLines(t, filepath.Join(dir, "slog-error.log"), `"req":{"err":"boom"}`); n != 1 {
+		t.Fatal("error record missing")
+	}
+}
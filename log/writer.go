@@ -0,0 +1,56 @@
+package log
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	writersMu sync.Mutex
+	writers   = make(map[string]*sharedWriter) // 按文件绝对路径共享
+)
+
+// sharedWriter 同一个文件只打开一个滚动写入器，多个Logger引用计数共用
+type sharedWriter struct {
+	io.WriteCloser
+	key  string
+	refs int
+}
+
+// openWriter 获取文件对应的写入器，文件已打开时沿用第一次打开时的滚动参数
+func openWriter(filename string, create func() io.WriteCloser) *sharedWriter {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		key = filepath.Clean(filename)
+	}
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	if w, ok := writers[key]; ok {
+		w.refs++
+		return w
+	}
+	w := &sharedWriter{WriteCloser: create(), key: key, refs: 1}
+	writers[key] = w
+	return w
+}
+
+// Sync 底层写入器支持时刷盘
+func (w *sharedWriter) Sync() error {
+	if s, ok := w.WriteCloser.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// release 引用计数归零时关闭文件
+func (w *sharedWriter) release() error {
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	w.refs--
+	if w.refs > 0 {
+		return nil
+	}
+	delete(writers, w.key)
+	return w.WriteCloser.Close()
+}